@@ -29,6 +29,38 @@ type Invocation interface {
 	MethodName() string
 }
 
+// JoinPoint 连接点元数据，Proxy创建的Invocation均实现该接口，通知可通过JoinPointOf获取
+type JoinPoint interface {
+	// Method 返回目标方法，Method.Type包含接收者
+	Method() reflect.Method
+
+	// TargetType 返回目标对象类型
+	TargetType() reflect.Type
+}
+
+// JoinPointOf 返回invocation的连接点元数据；invocation未实现JoinPoint时，
+// 返回的Method仅包含方法名，Type为无参数及返回值的函数类型，TargetType为invocation的类型
+func JoinPointOf(invocation Invocation) JoinPoint {
+	if jp, ok := invocation.(JoinPoint); ok {
+		return jp
+	}
+	return &unknownJoinPoint{invocation: invocation}
+}
+
+var emptyFuncType = reflect.TypeOf(func() {})
+
+type unknownJoinPoint struct {
+	invocation Invocation
+}
+
+func (p *unknownJoinPoint) Method() reflect.Method {
+	return reflect.Method{Name: p.invocation.MethodName(), Type: emptyFuncType}
+}
+
+func (p *unknownJoinPoint) TargetType() reflect.Type {
+	return reflect.TypeOf(p.invocation)
+}
+
 type PointCut interface {
 	Matches(method reflect.Method, instanceType reflect.Type, params ...interface{}) bool
 }
@@ -48,4 +80,40 @@ type Proxy interface {
 	// ret： 调用后返回的结果
 	// err： 调用成功返回nil，失败返回错误
 	Call(method string, params ...interface{}) (ret []interface{}, err error)
+
+	// CallE 调用方法，方法最后一个返回值为error时将其从结果中移除并作为err返回
+	// method： 方法名
+	// params： 方法参数
+	// ret： 调用后返回的结果，不包含最后一个error返回值
+	// err： 代理调用失败时返回代理错误（使用IsProxyError判断），否则返回方法返回的error
+	CallE(method string, params ...interface{}) (ret []interface{}, err error)
+
+	// CallInto 调用方法，并将结果赋值至dests中的指针，方法最后一个error返回值处理同CallE
+	// method： 方法名
+	// dests： 接收结果的指针，数量需与结果（不包含最后一个error）一致，为nil时忽略对应结果
+	// params： 方法参数
+	// err： 代理调用或结果赋值失败时返回代理错误（使用IsProxyError判断），否则返回方法返回的error
+	CallInto(method string, dests []interface{}, params ...interface{}) error
+
+	// Method 返回预先解析的方法句柄，重复调用同一方法时可跳过按名称查找
+	// method： 方法名
+	// 方法不存在时返回错误
+	Method(method string) (MethodHandle, error)
+}
+
+type MethodHandle interface {
+	// Name 返回方法名
+	Name() string
+
+	// Call 调用方法
+	// params： 方法参数
+	// ret： 调用后返回的结果
+	// err： 调用成功返回nil，失败返回错误
+	Call(params ...interface{}) (ret []interface{}, err error)
+
+	// CallE 调用方法，方法最后一个返回值为error时将其从结果中移除并作为err返回，同Proxy.CallE
+	CallE(params ...interface{}) (ret []interface{}, err error)
+
+	// CallInto 调用方法，并将结果赋值至dests中的指针，同Proxy.CallInto
+	CallInto(dests []interface{}, params ...interface{}) error
 }
@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package async
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	// ErrCanceled 调用已被取消
+	ErrCanceled = errors.New("async: future canceled")
+	// ErrQueueFull 等待队列已满，调用未执行
+	ErrQueueFull = errors.New("async: queue full")
+	// ErrClosed Executor已关闭，调用未执行
+	ErrClosed = errors.New("async: executor closed")
+)
+
+type task struct {
+	future     *Future
+	ctx        context.Context
+	invocation aop.Invocation
+	params     []interface{}
+}
+
+// Executor 执行异步调用的有界工作池
+type Executor struct {
+	tasks chan *task
+	wg    sync.WaitGroup
+
+	lock   sync.RWMutex
+	closed bool
+}
+
+// NewExecutor 创建工作池
+// workers： 工作协程数
+// queueSize： 等待执行的调用数上限，超出时Future以ErrQueueFull失败
+func NewExecutor(workers, queueSize int) *Executor {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	e := &Executor{
+		tasks: make(chan *task, queueSize),
+	}
+	e.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go e.work()
+	}
+	return e
+}
+
+// Advice 返回异步执行通知：Proxy.Call立即返回仅包含*Future的结果（使用FromResult获取），
+// 调用在工作池中执行。方法声明了context.Context参数时，传递给目标方法的context在Future.Cancel时取消。
+// 返回值与方法签名不一致，异步方法应使用Call，Proxy.CallE及CallInto返回*aop.AdviceResultError
+func (e *Executor) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		method := aop.JoinPointOf(invocation).Method()
+		index := methodfunc.ContextIndex(method)
+		var ctx context.Context
+		if index >= 0 && index < len(params) {
+			ctx, _ = params[index].(context.Context)
+		}
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		f := newFuture(invocation.MethodName(), method.Type, cancel)
+		if index >= 0 && index < len(params) {
+			ps := make([]interface{}, len(params))
+			copy(ps, params)
+			ps[index] = ctx
+			params = ps
+		}
+		e.submit(&task{
+			future:     f,
+			ctx:        ctx,
+			invocation: invocation,
+			params:     params,
+		})
+		return []interface{}{f}
+	}
+}
+
+// Pending 返回等待执行的调用数（包括已取消但未被工作协程移出队列的调用）
+func (e *Executor) Pending() int {
+	return len(e.tasks)
+}
+
+// Close 停止接收新的调用，等待已提交的调用执行完毕
+func (e *Executor) Close() {
+	e.lock.Lock()
+	if e.closed {
+		e.lock.Unlock()
+		return
+	}
+	e.closed = true
+	close(e.tasks)
+	e.lock.Unlock()
+	e.wg.Wait()
+}
+
+func (e *Executor) submit(t *task) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	if e.closed {
+		t.future.complete(nil, ErrClosed)
+		t.future.cancel()
+		return
+	}
+	select {
+	case e.tasks <- t:
+	default:
+		t.future.complete(nil, ErrQueueFull)
+		t.future.cancel()
+	}
+}
+
+func (e *Executor) work() {
+	defer e.wg.Done()
+	for t := range e.tasks {
+		e.run(t)
+	}
+}
+
+func (e *Executor) run(t *task) {
+	f := t.future
+	defer f.cancel()
+	if f.canceled() || t.ctx.Err() != nil {
+		f.complete(nil, t.ctx.Err())
+		return
+	}
+	defer func() {
+		if o := recover(); o != nil {
+			perr, ok := o.(*aop.PanicError)
+			if !ok {
+				perr = &aop.PanicError{Method: f.method, Value: o, Stack: debug.Stack()}
+			}
+			f.complete(nil, perr)
+		}
+	}()
+	f.complete(t.invocation.Invoke(t.params), nil)
+}
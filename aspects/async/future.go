@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package async
+
+import (
+	"context"
+	"github.com/xfali/aop/methodfunc"
+	"reflect"
+	"sync"
+)
+
+// Future 异步调用的结果
+type Future struct {
+	method   string
+	funcType reflect.Type
+	cancel   context.CancelFunc
+
+	once sync.Once
+	done chan struct{}
+	ret  []interface{}
+	err  error
+}
+
+func newFuture(method string, funcType reflect.Type, cancel context.CancelFunc) *Future {
+	return &Future{
+		method:   method,
+		funcType: funcType,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// FromResult 从Proxy.Call的返回值中获取Future
+func FromResult(ret []interface{}) (*Future, bool) {
+	if len(ret) != 1 {
+		return nil, false
+	}
+	f, ok := ret[0].(*Future)
+	return f, ok
+}
+
+// Method 返回方法名
+func (f *Future) Method() string {
+	return f.method
+}
+
+// Done 返回调用结束（完成、失败或取消）时关闭的channel
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Await 等待调用结束，返回值规则同Proxy.CallE：方法最后一个返回值为error时从结果中移除并作为err返回；
+// 目标方法panic时err为*aop.PanicError；ctx结束时返回ctx.Err()，不影响调用继续执行
+func (f *Future) Await(ctx context.Context) ([]interface{}, error) {
+	select {
+	case <-f.done:
+		return f.ret, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cancel 取消调用：未开始执行的调用不再执行；执行中的调用取消传递给目标方法的context，
+// 方法不接收context时继续执行但结果被丢弃。Await返回ErrCanceled
+func (f *Future) Cancel() {
+	f.complete(nil, ErrCanceled)
+	f.cancel()
+}
+
+func (f *Future) canceled() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *Future) complete(ret []interface{}, err error) {
+	f.once.Do(func() {
+		if err == nil && methodfunc.ReturnsError(f.funcType) && len(ret) > 0 {
+			n := len(ret)
+			err, _ = ret[n-1].(error)
+			ret = ret[:n-1]
+		}
+		f.ret = ret
+		f.err = err
+		close(f.done)
+	})
+}
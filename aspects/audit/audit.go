@@ -0,0 +1,204 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"context"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/auth"
+	"github.com/xfali/aop/aspects/logging"
+	"github.com/xfali/aop/methodfunc"
+	"log/slog"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomePanic   = "panic"
+)
+
+// Record 审计记录
+type Record struct {
+	Time      time.Time     `json:"time"`
+	Type      string        `json:"type"`
+	Method    string        `json:"method"`
+	Principal string        `json:"principal,omitempty"`
+	Args      []interface{} `json:"args"`
+	Outcome   string        `json:"outcome"`
+	Error     string        `json:"error,omitempty"`
+	// Results 结果摘要，默认为各返回值的类型
+	Results  interface{}   `json:"results,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+type Sink interface {
+	// Write 写入审计记录
+	Write(r *Record) error
+}
+
+// PrincipalFunc 返回调用者名称
+type PrincipalFunc func(invocation aop.Invocation, params []interface{}) string
+
+// ContextPrincipal 从参数的context.Context中获取auth.Principal的名称
+func ContextPrincipal(invocation aop.Invocation, params []interface{}) string {
+	if p, ok := auth.ContextExtractor(invocation, params); ok {
+		return p.Name()
+	}
+	return ""
+}
+
+// TypeSummary 以各返回值（不包含最后一个error）的类型作为结果摘要，避免记录业务数据
+func TypeSummary(invocation aop.Invocation, ret []interface{}) interface{} {
+	mt := aop.JoinPointOf(invocation).Method().Type
+	n := mt.NumOut()
+	if methodfunc.ReturnsError(mt) {
+		n--
+	}
+	types := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		types = append(types, mt.Out(i).String())
+	}
+	return types
+}
+
+// LogError 使用slog.Default()以ERROR级别输出写入失败的审计记录及错误，避免审计记录静默丢失
+func LogError(r *Record, err error) {
+	slog.Error("audit: write record failed",
+		"error", err,
+		"type", r.Type,
+		"method", r.Method,
+		"principal", r.Principal,
+		"outcome", r.Outcome,
+		"time", r.Time)
+}
+
+type auditor struct {
+	sink          Sink
+	principalFunc PrincipalFunc
+	summary       func(invocation aop.Invocation, ret []interface{}) interface{}
+	sensitive     map[string]map[int]bool
+	onError       func(r *Record, err error)
+}
+
+type Opt func(a *auditor)
+
+// New 创建审计通知，调用返回后写入审计记录；参数中标记为`log:"sensitive"`的结构体字段
+// 及OptSetSensitiveParams指定的参数替换为logging.Redacted
+// sink： 审计记录输出，如NewFileSink
+func New(sink Sink, opts ...Opt) aop.Advice {
+	a := &auditor{
+		sink:          sink,
+		principalFunc: ContextPrincipal,
+		summary:       TypeSummary,
+		onError:       LogError,
+		sensitive:     make(map[string]map[int]bool),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a.advice
+}
+
+// OptSetPrincipalFunc 设置获取调用者的方式，默认为ContextPrincipal
+func OptSetPrincipalFunc(f PrincipalFunc) Opt {
+	return func(a *auditor) {
+		a.principalFunc = f
+	}
+}
+
+// OptSetResultSummary 设置结果摘要，默认为TypeSummary
+func OptSetResultSummary(summary func(invocation aop.Invocation, ret []interface{}) interface{}) Opt {
+	return func(a *auditor) {
+		a.summary = summary
+	}
+}
+
+// OptSetSensitiveParams 标记指定方法的敏感参数
+// method： 方法名
+// indexes： 参数位置
+func OptSetSensitiveParams(method string, indexes ...int) Opt {
+	return func(a *auditor) {
+		m, ok := a.sensitive[method]
+		if !ok {
+			m = make(map[int]bool)
+			a.sensitive[method] = m
+		}
+		for _, i := range indexes {
+			m[i] = true
+		}
+	}
+}
+
+// OptSetOnError 设置写入审计记录失败时的回调，默认为LogError
+func OptSetOnError(hook func(r *Record, err error)) Opt {
+	return func(a *auditor) {
+		a.onError = hook
+	}
+}
+
+func (a *auditor) advice(invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+	r := &Record{
+		Time:      time.Now(),
+		Type:      aop.JoinPointOf(invocation).TargetType().String(),
+		Method:    invocation.MethodName(),
+		Principal: a.principalFunc(invocation, params),
+		Args:      a.redactArgs(invocation.MethodName(), params),
+	}
+	defer func() {
+		r.Duration = time.Since(r.Time)
+		if o := recover(); o != nil {
+			r.Outcome = OutcomePanic
+			r.Error = fmt.Sprintf("%v", o)
+			a.write(r)
+			panic(o)
+		}
+		r.Outcome = OutcomeSuccess
+		if err := methodfunc.TrailingError(aop.JoinPointOf(invocation).Method().Type, ret); err != nil {
+			r.Outcome = OutcomeError
+			r.Error = err.Error()
+		}
+		r.Results = a.summary(invocation, ret)
+		a.write(r)
+	}()
+	return invocation.Invoke(params)
+}
+
+func (a *auditor) write(r *Record) {
+	if err := a.sink.Write(r); err != nil && a.onError != nil {
+		a.onError(r, err)
+	}
+}
+
+func (a *auditor) redactArgs(method string, params []interface{}) []interface{} {
+	sensitive := a.sensitive[method]
+	ret := make([]interface{}, 0, len(params))
+	for i, p := range params {
+		// context不记录
+		if _, ok := p.(context.Context); ok {
+			continue
+		}
+		if sensitive[i] {
+			ret = append(ret, logging.Redacted)
+		} else {
+			ret = append(ret, logging.Redact(p))
+		}
+	}
+	return ret
+}
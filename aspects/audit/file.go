@@ -0,0 +1,166 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type SyncPolicy int
+
+const (
+	// SyncNever 不主动fsync，由操作系统决定落盘时机
+	SyncNever SyncPolicy = iota
+	// SyncEveryRecord 每条记录写入后fsync
+	SyncEveryRecord
+	// SyncInterval 距上次fsync超过指定间隔时，在写入后fsync
+	SyncInterval
+)
+
+// FileSink 以追加方式写入JSON lines文件，文件超过指定大小时轮转
+type FileSink struct {
+	path         string
+	maxSize      int64
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
+
+	lock     sync.Mutex
+	file     *os.File
+	size     int64
+	lastSync time.Time
+}
+
+type FileOpt func(s *FileSink)
+
+// NewFileSink 打开审计文件，文件已存在时追加写入
+// path： 文件路径，轮转后的文件为path.<时间戳>
+func NewFileSink(path string, opts ...FileOpt) (*FileSink, error) {
+	s := &FileSink{
+		path: path,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OptSetMaxSize 设置文件轮转大小（字节），为0时不轮转
+func OptSetMaxSize(size int64) FileOpt {
+	return func(s *FileSink) {
+		s.maxSize = size
+	}
+}
+
+// OptSetSync 设置fsync策略，policy为SyncInterval时interval为fsync间隔
+func OptSetSync(policy SyncPolicy, interval time.Duration) FileOpt {
+	return func(s *FileSink) {
+		s.syncPolicy = policy
+		s.syncInterval = interval
+	}
+}
+
+func (s *FileSink) Write(r *Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	var rotateErr error
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(b)) > s.maxSize {
+		// 轮转失败但已重新打开原文件时仍写入记录，并返回轮转错误
+		if rotateErr = s.rotate(); s.file == nil {
+			return rotateErr
+		}
+	}
+	n, err := s.file.Write(b)
+	s.size += int64(n)
+	if err != nil {
+		return errors.Join(rotateErr, err)
+	}
+	var syncErr error
+	switch s.syncPolicy {
+	case SyncEveryRecord:
+		syncErr = s.file.Sync()
+	case SyncInterval:
+		if now := time.Now(); now.Sub(s.lastSync) >= s.syncInterval {
+			s.lastSync = now
+			syncErr = s.file.Sync()
+		}
+	}
+	return errors.Join(rotateErr, syncErr)
+}
+
+// Close 同步并关闭文件
+func (s *FileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	s.file.Sync()
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	s.lastSync = time.Now()
+	return nil
+}
+
+// rotate 重命名当前文件并打开新文件；重命名失败时重新打开原文件继续追加写入
+func (s *FileSink) rotate() error {
+	s.file.Sync()
+	err := s.file.Close()
+	s.file = nil
+	if err == nil {
+		backup := fmt.Sprintf("%s.%s", s.path, time.Now().Format("20060102T150405.000000000"))
+		if err = os.Rename(s.path, backup); err != nil {
+			err = fmt.Errorf("audit: rotate %s: %w", s.path, err)
+		}
+	}
+	if openErr := s.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"time"
+)
+
+// ErrForbidden 无权调用，可通过errors.Is(err, ErrForbidden)判断
+var ErrForbidden = errors.New("auth: forbidden")
+
+type ForbiddenError struct {
+	Method string
+	// Principal 主体名称，未认证时为空
+	Principal string
+	Reason    string
+}
+
+func (e *ForbiddenError) Error() string {
+	if e.Principal == "" {
+		return fmt.Sprintf("auth: method %s forbidden: %s", e.Method, e.Reason)
+	}
+	return fmt.Sprintf("auth: method %s forbidden for %s: %s", e.Method, e.Principal, e.Reason)
+}
+
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Rule 方法的访问规则：Roles满足任一即可，Permissions需全部满足，
+// 均为空时只要求已认证；Anonymous为true时允许未认证调用
+type Rule struct {
+	Roles       []string
+	Permissions []string
+	Anonymous   bool
+}
+
+// Policy 访问规则表，key依次匹配"类型.方法"（如*service.UserService.Delete）、方法名及"*"，
+// 均未匹配时拒绝
+type Policy map[string]Rule
+
+// Extractor 从调用中获取主体
+type Extractor func(invocation aop.Invocation, params []interface{}) (Principal, bool)
+
+// ContextExtractor 从方法声明的context.Context参数中获取主体
+func ContextExtractor(invocation aop.Invocation, params []interface{}) (Principal, bool) {
+	ctx, index := methodfunc.MethodContext(aop.JoinPointOf(invocation).Method(), params)
+	if index < 0 {
+		return nil, false
+	}
+	return FromContext(ctx)
+}
+
+// Denial 拒绝调用的审计记录
+type Denial struct {
+	Time      time.Time
+	Type      string
+	Method    string
+	Principal Principal
+	Reason    string
+}
+
+type authorizer struct {
+	policy    Policy
+	extractor Extractor
+	onDeny    func(d Denial)
+}
+
+type Opt func(a *authorizer)
+
+// New 创建鉴权通知，拒绝时不调用目标方法：方法最后一个返回值为error时返回*ForbiddenError，
+// 否则以*ForbiddenError panic
+// policy： 访问规则表
+func New(policy Policy, opts ...Opt) aop.Advice {
+	a := &authorizer{
+		policy:    policy,
+		extractor: ContextExtractor,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a.advice
+}
+
+// OptSetExtractor 设置获取主体的方式，默认为ContextExtractor
+func OptSetExtractor(extractor Extractor) Opt {
+	return func(a *authorizer) {
+		a.extractor = extractor
+	}
+}
+
+// OptSetOnDeny 设置拒绝调用时的审计回调
+func OptSetOnDeny(hook func(d Denial)) Opt {
+	return func(a *authorizer) {
+		a.onDeny = hook
+	}
+}
+
+func (a *authorizer) advice(invocation aop.Invocation, params []interface{}) []interface{} {
+	p, ok := a.extractor(invocation, params)
+	if !ok {
+		p = nil
+	}
+	reason := a.check(invocation, p)
+	if reason == "" {
+		return invocation.Invoke(params)
+	}
+
+	if a.onDeny != nil {
+		a.onDeny(Denial{
+			Time:      time.Now(),
+			Type:      aop.JoinPointOf(invocation).TargetType().String(),
+			Method:    invocation.MethodName(),
+			Principal: p,
+			Reason:    reason,
+		})
+	}
+	err := &ForbiddenError{Method: invocation.MethodName(), Reason: reason}
+	if p != nil {
+		err.Principal = p.Name()
+	}
+	return methodfunc.ErrorResults(aop.JoinPointOf(invocation).Method().Type, err)
+}
+
+// check 校验通过返回空字符串，否则返回拒绝原因
+func (a *authorizer) check(invocation aop.Invocation, p Principal) string {
+	rule, ok := a.policy[aop.JoinPointOf(invocation).TargetType().String()+"."+invocation.MethodName()]
+	if !ok {
+		rule, ok = a.policy[invocation.MethodName()]
+	}
+	if !ok {
+		rule, ok = a.policy["*"]
+	}
+	if !ok {
+		return "no policy"
+	}
+	if rule.Anonymous {
+		return ""
+	}
+	if p == nil {
+		return "unauthenticated"
+	}
+	if len(rule.Roles) > 0 {
+		matched := false
+		for _, r := range rule.Roles {
+			if p.HasRole(r) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Sprintf("require one of roles %v", rule.Roles)
+		}
+	}
+	for _, perm := range rule.Permissions {
+		if !p.HasPermission(perm) {
+			return "require permission " + perm
+		}
+	}
+	return ""
+}
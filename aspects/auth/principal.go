@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import "context"
+
+type Principal interface {
+	// Name 返回主体名称
+	Name() string
+
+	// HasRole 判断是否拥有角色
+	HasRole(role string) bool
+
+	// HasPermission 判断是否拥有权限
+	HasPermission(permission string) bool
+}
+
+// User Principal的简单实现
+type User struct {
+	ID          string
+	Roles       []string
+	Permissions []string
+}
+
+func (u *User) Name() string {
+	return u.ID
+}
+
+func (u *User) HasRole(role string) bool {
+	return contains(u.Roles, role)
+}
+
+func (u *User) HasPermission(permission string) bool {
+	return contains(u.Permissions, permission)
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal 返回携带主体的context
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 返回context中的主体
+func FromContext(ctx context.Context) (Principal, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok && p != nil
+}
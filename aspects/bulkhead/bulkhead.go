@@ -0,0 +1,246 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bulkhead
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrRejected 调用被舱壁拒绝，可通过errors.Is(err, ErrRejected)判断
+var ErrRejected = errors.New("bulkhead: rejected")
+
+type Reason int
+
+const (
+	// QueueFull 等待队列已满
+	QueueFull Reason = iota
+	// QueueTimeout 等待超时
+	QueueTimeout
+)
+
+func (r Reason) String() string {
+	if r == QueueTimeout {
+		return "queue timeout"
+	}
+	return "queue full"
+}
+
+type RejectedError struct {
+	Group  string
+	Reason Reason
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("bulkhead: %s rejected: %s", e.Group, e.Reason)
+}
+
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Stats 舱壁的统计数据
+type Stats struct {
+	InFlight int
+	Waiting  int
+	// Rejected 被拒绝的调用次数（包含等待超时）
+	Rejected uint64
+	// Timeouts 等待超时的调用次数
+	Timeouts uint64
+}
+
+// GroupFunc 返回调用所属的分组，同一分组共享并发限制
+type GroupFunc func(invocation aop.Invocation, params []interface{}) string
+
+type group struct {
+	sem   chan struct{}
+	lock  sync.Mutex
+	stats Stats
+}
+
+type Bulkhead struct {
+	maxConcurrent int
+	maxQueue      int
+	queueTimeout  time.Duration
+	groupFunc     GroupFunc
+	groupNames    map[string]string
+	onReject      func(group string, reason Reason)
+
+	lock   sync.Mutex
+	groups map[string]*group
+}
+
+type Opt func(b *Bulkhead)
+
+// New 创建舱壁，默认每个目标方法为一个分组，不允许排队等待
+// maxConcurrent： 每个分组允许的最大并发调用数
+func New(maxConcurrent int, opts ...Opt) *Bulkhead {
+	b := &Bulkhead{
+		maxConcurrent: maxConcurrent,
+		groupNames:    make(map[string]string),
+		groups:        make(map[string]*group),
+	}
+	b.groupFunc = b.defaultGroup
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// OptSetGroup 将多个方法归入同一分组，共享并发限制
+func OptSetGroup(name string, methods ...string) Opt {
+	return func(b *Bulkhead) {
+		for _, m := range methods {
+			b.groupNames[m] = name
+		}
+	}
+}
+
+// OptSetGroupFunc 自定义分组方式
+func OptSetGroupFunc(groupFunc GroupFunc) Opt {
+	return func(b *Bulkhead) {
+		b.groupFunc = groupFunc
+	}
+}
+
+// OptSetMaxQueue 设置每个分组允许排队等待的调用数，队列已满时立即拒绝
+func OptSetMaxQueue(n int) Opt {
+	return func(b *Bulkhead) {
+		b.maxQueue = n
+	}
+}
+
+// OptSetQueueTimeout 设置排队等待的最长时间，为0时一直等待；
+// 方法声明了context.Context参数时，context结束后停止等待并返回context的错误
+func OptSetQueueTimeout(d time.Duration) Opt {
+	return func(b *Bulkhead) {
+		b.queueTimeout = d
+	}
+}
+
+// OptSetOnReject 设置调用被拒绝时的回调
+func OptSetOnReject(hook func(group string, reason Reason)) Opt {
+	return func(b *Bulkhead) {
+		b.onReject = hook
+	}
+}
+
+// Stats 返回分组的统计数据
+func (b *Bulkhead) Stats(name string) Stats {
+	g := b.get(name)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.stats
+}
+
+// Advice 返回舱壁通知，调用被拒绝时：方法最后一个返回值为error时返回*RejectedError，
+// 否则以*RejectedError panic
+func (b *Bulkhead) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		name := b.groupFunc(invocation, params)
+		g := b.get(name)
+		if err := b.acquire(name, g, aop.JoinPointOf(invocation).Method(), params); err != nil {
+			return methodfunc.ErrorResults(aop.JoinPointOf(invocation).Method().Type, err)
+		}
+		defer b.release(g)
+		return invocation.Invoke(params)
+	}
+}
+
+func (b *Bulkhead) defaultGroup(invocation aop.Invocation, params []interface{}) string {
+	if name, ok := b.groupNames[invocation.MethodName()]; ok {
+		return name
+	}
+	return aop.JoinPointOf(invocation).TargetType().String() + "." + invocation.MethodName()
+}
+
+func (b *Bulkhead) get(name string) *group {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	g, ok := b.groups[name]
+	if !ok {
+		g = &group{sem: make(chan struct{}, b.maxConcurrent)}
+		b.groups[name] = g
+	}
+	return g
+}
+
+func (b *Bulkhead) acquire(name string, g *group, method reflect.Method, params []interface{}) error {
+	g.lock.Lock()
+	select {
+	case g.sem <- struct{}{}:
+		g.stats.InFlight++
+		g.lock.Unlock()
+		return nil
+	default:
+	}
+	if g.stats.Waiting >= b.maxQueue {
+		g.stats.Rejected++
+		g.lock.Unlock()
+		return b.reject(name, QueueFull)
+	}
+	g.stats.Waiting++
+	g.lock.Unlock()
+
+	ctx, _ := methodfunc.MethodContext(method, params)
+	var timeout <-chan time.Time
+	if b.queueTimeout > 0 {
+		timer := time.NewTimer(b.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case g.sem <- struct{}{}:
+		g.lock.Lock()
+		g.stats.Waiting--
+		g.stats.InFlight++
+		g.lock.Unlock()
+		return nil
+	case <-timeout:
+		g.lock.Lock()
+		g.stats.Waiting--
+		g.stats.Rejected++
+		g.stats.Timeouts++
+		g.lock.Unlock()
+		return b.reject(name, QueueTimeout)
+	case <-ctx.Done():
+		g.lock.Lock()
+		g.stats.Waiting--
+		g.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (b *Bulkhead) release(g *group) {
+	g.lock.Lock()
+	g.stats.InFlight--
+	g.lock.Unlock()
+	<-g.sem
+}
+
+func (b *Bulkhead) reject(name string, reason Reason) error {
+	if b.onReject != nil {
+		b.onReject(name, reason)
+	}
+	return &RejectedError{Group: name, Reason: reason}
+}
@@ -0,0 +1,244 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"container/list"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key     string
+	method  string
+	ret     []interface{}
+	expires time.Time
+}
+
+type Cache struct {
+	keyFunc     KeyFunc
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	lock    sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// generations 方法缓存被清除的次数，调用期间发生变化时不缓存结果
+	generations map[string]uint64
+	// epoch Purge的次数
+	epoch uint64
+}
+
+type generation struct {
+	epoch  uint64
+	method uint64
+}
+
+type Opt func(c *Cache)
+
+// New 创建缓存，默认缓存1分钟、最多1024条，不缓存返回error的结果
+func New(opts ...Opt) *Cache {
+	c := &Cache{
+		keyFunc:    HashKey,
+		ttl:        time.Minute,
+		maxEntries: 1024,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+
+		generations: make(map[string]uint64),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// OptSetKeyFunc 设置缓存key生成方式，默认为HashKey
+func OptSetKeyFunc(keyFunc KeyFunc) Opt {
+	return func(c *Cache) {
+		c.keyFunc = keyFunc
+	}
+}
+
+// OptSetTTL 设置缓存有效时间，为0时永不过期
+func OptSetTTL(ttl time.Duration) Opt {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// OptSetNegativeTTL 设置返回error的结果的缓存时间，为0时不缓存（默认）
+func OptSetNegativeTTL(ttl time.Duration) Opt {
+	return func(c *Cache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// OptSetMaxEntries 设置最大缓存条数，超出时淘汰最近最少使用的条目，为0时不限制
+func OptSetMaxEntries(n int) Opt {
+	return func(c *Cache) {
+		c.maxEntries = n
+	}
+}
+
+// Advice 返回缓存通知，key为目标类型、方法名及参数；
+// 调用期间该方法的缓存被清除（EvictAdvice、Invalidate等）时不缓存结果，避免缓存清除前读取的旧值
+func (c *Cache) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		method := methodKey(invocation)
+		key := method + ":" + c.keyFunc(params)
+		ret, gen, ok := c.get(key, method)
+		if ok {
+			return ret
+		}
+		ret = invocation.Invoke(params)
+		ttl := c.ttl
+		if methodfunc.TrailingError(aop.JoinPointOf(invocation).Method().Type, ret) != nil {
+			if c.negativeTTL <= 0 {
+				return ret
+			}
+			ttl = c.negativeTTL
+		}
+		c.put(key, method, gen, ret, ttl)
+		return ret
+	}
+}
+
+// EvictAdvice 返回使缓存失效的通知，调用返回后清除同一目标类型上指定方法的全部缓存，
+// 如为Save*方法设置以清除Get*方法的缓存
+// methods： 需清除缓存的方法名
+func (c *Cache) EvictAdvice(methods ...string) aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		defer func() {
+			t := aop.JoinPointOf(invocation).TargetType().String()
+			for _, m := range methods {
+				c.evictMethod(t + "." + m)
+			}
+		}()
+		return invocation.Invoke(params)
+	}
+}
+
+// Invalidate 清除目标类型上指定方法及参数的缓存
+// target： 被代理的对象
+func (c *Cache) Invalidate(target interface{}, method string, params ...interface{}) {
+	key := typeName(target) + "." + method + ":" + c.keyFunc(params)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generations[typeName(target)+"."+method]++
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+}
+
+// InvalidateMethod 清除目标类型上指定方法的全部缓存
+// target： 被代理的对象
+func (c *Cache) InvalidateMethod(target interface{}, method string) {
+	c.evictMethod(typeName(target) + "." + method)
+}
+
+// Purge 清除全部缓存
+func (c *Cache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.epoch++
+}
+
+// Len 返回缓存条数（包含已过期未清除的条目）
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// get 返回缓存结果，未命中时返回方法当前的generation
+func (c *Cache) get(key, method string) ([]interface{}, generation, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	gen := generation{epoch: c.epoch, method: c.generations[method]}
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, gen, false
+	}
+	en := e.Value.(*entry)
+	if !en.expires.IsZero() && time.Now().After(en.expires) {
+		c.remove(e)
+		return nil, gen, false
+	}
+	c.lru.MoveToFront(e)
+	// 返回副本，避免通知修改结果影响缓存
+	return append([]interface{}(nil), en.ret...), gen, true
+}
+
+// put 缓存结果，gen与方法当前的generation不一致时（调用期间缓存被清除）不缓存
+func (c *Cache) put(key, method string, gen generation, ret []interface{}, ttl time.Duration) {
+	en := &entry{
+		key:    key,
+		method: method,
+		ret:    append([]interface{}(nil), ret...),
+	}
+	if ttl > 0 {
+		en.expires = time.Now().Add(ttl)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if gen != (generation{epoch: c.epoch, method: c.generations[method]}) {
+		return
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value = en
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(en)
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) evictMethod(method string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generations[method]++
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*entry).method == method {
+			c.remove(e)
+		}
+		e = next
+	}
+}
+
+func (c *Cache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*entry).key)
+}
+
+func methodKey(invocation aop.Invocation) string {
+	return aop.JoinPointOf(invocation).TargetType().String() + "." + invocation.MethodName()
+}
+
+func typeName(target interface{}) string {
+	return reflect.TypeOf(target).String()
+}
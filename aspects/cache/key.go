@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// KeyFunc 根据参数生成缓存key，同一方法相同key的调用共享缓存
+type KeyFunc func(params []interface{}) string
+
+// HashKey 默认的key生成方式：对参数做确定性的哈希，指针按指向的值计算，
+// map按key排序，context.Context参数不参与计算
+func HashKey(params []interface{}) string {
+	h := fnv.New128a()
+	for _, p := range params {
+		if _, ok := p.(context.Context); ok {
+			continue
+		}
+		writeValue(h, reflect.ValueOf(p), 0)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeValue(h hash.Hash, v reflect.Value, depth int) {
+	if !v.IsValid() {
+		h.Write([]byte("nil"))
+		return
+	}
+	// 防止循环引用
+	if depth > 32 {
+		h.Write([]byte("..."))
+		return
+	}
+	h.Write([]byte(v.Type().String()))
+	h.Write([]byte{':'})
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			h.Write([]byte("nil"))
+			return
+		}
+		writeValue(h, v.Elem(), depth+1)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			h.Write([]byte(v.Type().Field(i).Name))
+			h.Write([]byte{'='})
+			writeValue(h, v.Field(i), depth+1)
+			h.Write([]byte{','})
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			h.Write([]byte("nil"))
+			return
+		}
+		h.Write([]byte(strconv.Itoa(v.Len())))
+		for i := 0; i < v.Len(); i++ {
+			h.Write([]byte{','})
+			writeValue(h, v.Index(i), depth+1)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			h.Write([]byte("nil"))
+			return
+		}
+		keys := v.MapKeys()
+		sorted := make([]string, len(keys))
+		index := make(map[string]reflect.Value, len(keys))
+		for i, k := range keys {
+			sorted[i] = fmt.Sprintf("%#v", k)
+			index[sorted[i]] = k
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			h.Write([]byte(k))
+			h.Write([]byte{'='})
+			writeValue(h, v.MapIndex(index[k]), depth+1)
+			h.Write([]byte{','})
+		}
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		h.Write([]byte(strconv.FormatUint(uint64(v.Pointer()), 16)))
+	case reflect.String:
+		h.Write([]byte(strconv.Quote(v.String())))
+	case reflect.Bool:
+		h.Write([]byte(strconv.FormatBool(v.Bool())))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		h.Write([]byte(strconv.FormatInt(v.Int(), 10)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		h.Write([]byte(strconv.FormatUint(v.Uint(), 10)))
+	case reflect.Float32, reflect.Float64:
+		h.Write([]byte(strconv.FormatFloat(v.Float(), 'g', -1, 64)))
+	case reflect.Complex64, reflect.Complex128:
+		h.Write([]byte(strconv.FormatComplex(v.Complex(), 'g', -1, 128)))
+	}
+}
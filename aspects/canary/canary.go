@@ -0,0 +1,245 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package canary
+
+import (
+	"context"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/cache"
+	"github.com/xfali/aop/methodfunc"
+	"hash/fnv"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type Reason int
+
+const (
+	// ReasonContext 由context中指定的目标路由
+	ReasonContext Reason = iota
+	// ReasonSticky 由参数key的哈希按权重路由
+	ReasonSticky
+	// ReasonWeight 按权重随机路由
+	ReasonWeight
+	// ReasonDefault 所有目标权重为0时路由至基础目标
+	ReasonDefault
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonContext:
+		return "context"
+	case ReasonSticky:
+		return "sticky"
+	case ReasonWeight:
+		return "weight"
+	}
+	return "default"
+}
+
+// Route 一次路由结果
+type Route struct {
+	Type   string
+	Method string
+	Target string
+	Reason Reason
+}
+
+type targetKey struct{}
+
+// WithTarget 返回指定路由目标的context，目标不存在时忽略
+func WithTarget(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, targetKey{}, name)
+}
+
+type target struct {
+	name   string
+	proxy  aop.Proxy
+	weight int
+}
+
+type Router struct {
+	base      string
+	stickyKey cache.KeyFunc
+	onRoute   func(r Route)
+
+	lock    sync.RWMutex
+	targets []*target
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+}
+
+type Opt func(r *Router)
+
+// New 创建路由器
+// base： 基础目标名称，即Proxy代理的对象，初始权重为100
+func New(base string, opts ...Opt) *Router {
+	r := &Router{
+		base:    base,
+		targets: []*target{{name: base, weight: 100}},
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// OptAddTarget 添加路由目标，目标需包含与基础目标同名的方法
+// name： 目标名称
+// impl： 目标对象
+// weight： 权重
+func OptAddTarget(name string, impl interface{}, weight int) Opt {
+	return func(r *Router) {
+		r.targets = append(r.targets, &target{name: name, proxy: aop.New(impl), weight: weight})
+	}
+}
+
+// OptSetStickyKey 设置粘性路由key，key不为空时按key的哈希路由：key哈希至固定的routeBuckets个桶，
+// 非基础目标按添加顺序从低位起占用与权重占比相应的桶，基础目标占用其余的桶。
+// 因此只有一个非基础目标时，逐步提高其权重只会使部分key由基础目标迁移至该目标，已路由至该目标的key保持不变
+func OptSetStickyKey(keyFunc cache.KeyFunc) Opt {
+	return func(r *Router) {
+		r.stickyKey = keyFunc
+	}
+}
+
+// OptSetSeed 设置按权重随机路由的随机数种子
+func OptSetSeed(seed int64) Opt {
+	return func(r *Router) {
+		r.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// OptSetOnRoute 设置路由回调
+func OptSetOnRoute(hook func(r Route)) Opt {
+	return func(r *Router) {
+		r.onRoute = hook
+	}
+}
+
+// SetWeight 运行时调整目标权重，用于逐步放量，目标不存在时返回false
+func (r *Router) SetWeight(name string, weight int) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, t := range r.targets {
+		if t.name == name {
+			t.weight = weight
+			return true
+		}
+	}
+	return false
+}
+
+// Proxy 返回代理基础目标并按路由规则分发所有方法调用的Proxy。
+// 路由通知始终为最内层通知，之后通过AddAdvisor添加的通知（鉴权、校验、事务等）对所有目标生效，
+// 其连接点为基础目标的方法
+// impl： 基础目标对象
+func (r *Router) Proxy(impl interface{}, opts ...aop.Opt) aop.Proxy {
+	return newRoutedProxy(r, impl, opts)
+}
+
+// Advice 返回路由通知，按以下顺序选择目标：方法声明的context.Context参数中由WithTarget指定的目标；
+// 设置了粘性路由key且key不为空时按key的哈希及权重选择；否则按权重随机选择。
+// 选中基础目标时调用基础目标，否则直接调用选中目标的同名方法，因此该通知需为最内层通知（最后添加），
+// 否则其后的通知不会作用于非基础目标，通常使用Proxy；调用失败时方法最后一个返回值为error则返回错误，否则panic
+func (r *Router) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		t, reason := r.route(aop.JoinPointOf(invocation).Method(), params)
+		if r.onRoute != nil {
+			r.onRoute(Route{
+				Type:   aop.JoinPointOf(invocation).TargetType().String(),
+				Method: invocation.MethodName(),
+				Target: t.name,
+				Reason: reason,
+			})
+		}
+		if t.proxy == nil {
+			return invocation.Invoke(params)
+		}
+		ret, err := t.proxy.Call(invocation.MethodName(), params...)
+		if err != nil {
+			return methodfunc.ErrorResults(aop.JoinPointOf(invocation).Method().Type, fmt.Errorf("canary: route %s to %s: %w", invocation.MethodName(), t.name, err))
+		}
+		return ret
+	}
+}
+
+// routeBuckets 粘性路由及随机路由的桶数
+const routeBuckets = 10000
+
+func (r *Router) route(method reflect.Method, params []interface{}) (target, Reason) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if index := methodfunc.ContextIndex(method); index >= 0 && index < len(params) {
+		if ctx, ok := params[index].(context.Context); ok && ctx != nil {
+			if name, ok := ctx.Value(targetKey{}).(string); ok {
+				for _, t := range r.targets {
+					if t.name == name {
+						return *t, ReasonContext
+					}
+				}
+			}
+		}
+	}
+
+	total := 0
+	for _, t := range r.targets {
+		if t.weight > 0 {
+			total += t.weight
+		}
+	}
+	if total == 0 {
+		return *r.targets[0], ReasonDefault
+	}
+
+	var n int
+	reason := ReasonWeight
+	key := ""
+	if r.stickyKey != nil {
+		key = r.stickyKey(params)
+	}
+	if key != "" {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		n = int(h.Sum64() % routeBuckets)
+		reason = ReasonSticky
+	} else {
+		r.randLock.Lock()
+		n = r.rand.Intn(routeBuckets)
+		r.randLock.Unlock()
+	}
+	// 非基础目标在前，基础目标在最后，按累计权重划分桶
+	cum := 0
+	for i := range r.targets {
+		t := r.targets[(i+1)%len(r.targets)]
+		if t.weight <= 0 {
+			continue
+		}
+		cum += t.weight
+		if n < cum*routeBuckets/total {
+			return *t, reason
+		}
+	}
+	return *r.targets[0], ReasonDefault
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package canary
+
+import (
+	"github.com/xfali/aop"
+	"sync"
+)
+
+type advisor struct {
+	pointCut aop.PointCut
+	advice   aop.Advice
+}
+
+// routedProxy 保持路由通知为最内层通知，后添加的通知同样作用于所有目标
+type routedProxy struct {
+	router *Router
+	impl   interface{}
+	opts   []aop.Opt
+
+	lock     sync.RWMutex
+	advisors []advisor
+	proxy    aop.Proxy
+	version  uint64
+}
+
+func newRoutedProxy(router *Router, impl interface{}, opts []aop.Opt) *routedProxy {
+	p := &routedProxy{
+		router: router,
+		impl:   impl,
+		opts:   opts,
+	}
+	p.rebuild()
+	return p
+}
+
+// AddAdvisor 添加通知，通知在路由之前执行，连接点为基础目标的方法
+func (p *routedProxy) AddAdvisor(pointCut aop.PointCut, advice aop.Advice) aop.Proxy {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.advisors = append(p.advisors, advisor{pointCut: pointCut, advice: advice})
+	p.rebuild()
+	return p
+}
+
+func (p *routedProxy) Call(method string, params ...interface{}) ([]interface{}, error) {
+	return p.current().Call(method, params...)
+}
+
+func (p *routedProxy) CallE(method string, params ...interface{}) ([]interface{}, error) {
+	return p.current().CallE(method, params...)
+}
+
+func (p *routedProxy) CallInto(method string, dests []interface{}, params ...interface{}) error {
+	return p.current().CallInto(method, dests, params...)
+}
+
+func (p *routedProxy) Method(method string) (aop.MethodHandle, error) {
+	p.lock.RLock()
+	h, err := p.proxy.Method(method)
+	version := p.version
+	p.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return &routedHandle{proxy: p, name: method, handle: h, version: version}, nil
+}
+
+func (p *routedProxy) current() aop.Proxy {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.proxy
+}
+
+// rebuild 重新创建代理，路由通知最后添加即为最内层，需持有写锁
+func (p *routedProxy) rebuild() {
+	proxy := aop.New(p.impl, p.opts...)
+	for _, a := range p.advisors {
+		proxy.AddAdvisor(a.pointCut, a.advice)
+	}
+	proxy.AddAdvisor(aop.PointCutRegExp("", "", nil, nil), p.router.Advice())
+	p.proxy = proxy
+	p.version++
+}
+
+// routedHandle 代理重建后重新获取方法句柄
+type routedHandle struct {
+	proxy *routedProxy
+
+	lock    sync.Mutex
+	name    string
+	handle  aop.MethodHandle
+	version uint64
+}
+
+func (h *routedHandle) Name() string {
+	return h.name
+}
+
+func (h *routedHandle) Call(params ...interface{}) ([]interface{}, error) {
+	return h.current().Call(params...)
+}
+
+func (h *routedHandle) CallE(params ...interface{}) ([]interface{}, error) {
+	return h.current().CallE(params...)
+}
+
+func (h *routedHandle) CallInto(dests []interface{}, params ...interface{}) error {
+	return h.current().CallInto(dests, params...)
+}
+
+func (h *routedHandle) current() aop.MethodHandle {
+	h.proxy.lock.RLock()
+	proxy, version := h.proxy.proxy, h.proxy.version
+	h.proxy.lock.RUnlock()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if version != h.version {
+		// 方法已存在，重建后不会失败
+		if handle, err := proxy.Method(h.name); err == nil {
+			h.handle, h.version = handle, version
+		}
+	}
+	return h.handle
+}
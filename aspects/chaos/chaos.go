@@ -0,0 +1,218 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjected 未指定错误时注入的错误
+var ErrInjected = errors.New("chaos: injected fault")
+
+type Kind int
+
+const (
+	// KindLatency 调用前延迟
+	KindLatency Kind = iota
+	// KindError 不调用目标方法，在最后一个error返回值中返回错误
+	KindError
+	// KindPanic 不调用目标方法，直接panic
+	KindPanic
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindError:
+		return "error"
+	case KindPanic:
+		return "panic"
+	}
+	return "latency"
+}
+
+// Fault 故障规则
+type Fault struct {
+	Kind Kind
+	// Method 匹配的方法，可以为"Type.Method"（Type为reflect.Type.String()）、方法名或"*"
+	Method string
+	// Probability 注入概率，范围[0, 1]
+	Probability float64
+	// Latency KindLatency的延迟
+	Latency time.Duration
+	// Err KindError返回的错误，为nil时为ErrInjected
+	Err error
+	// Value KindPanic的panic值，为nil时为ErrInjected
+	Value interface{}
+}
+
+// Injection 一次故障注入
+type Injection struct {
+	Type   string
+	Method string
+	Fault  Fault
+}
+
+type Injector struct {
+	faults   []Fault
+	onInject func(i Injection)
+	enabled  int32
+
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+type Opt func(i *Injector)
+
+// New 创建故障注入器，默认启用
+func New(opts ...Opt) *Injector {
+	i := &Injector{
+		enabled: 1,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// OptSetSeed 设置随机数种子，相同种子及调用顺序下注入结果可复现
+func OptSetSeed(seed int64) Opt {
+	return func(i *Injector) {
+		i.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// OptAddFault 添加故障规则，按添加顺序判断：延迟累加，错误或panic命中后不再判断后续规则
+func OptAddFault(f Fault) Opt {
+	return func(i *Injector) {
+		i.faults = append(i.faults, f)
+	}
+}
+
+// OptAddLatency 添加延迟规则
+func OptAddLatency(method string, probability float64, latency time.Duration) Opt {
+	return OptAddFault(Fault{Kind: KindLatency, Method: method, Probability: probability, Latency: latency})
+}
+
+// OptAddError 添加错误规则，err为nil时返回ErrInjected；方法最后一个返回值不为error时不注入
+func OptAddError(method string, probability float64, err error) Opt {
+	return OptAddFault(Fault{Kind: KindError, Method: method, Probability: probability, Err: err})
+}
+
+// OptAddPanic 添加panic规则，value为nil时以ErrInjected panic
+func OptAddPanic(method string, probability float64, value interface{}) Opt {
+	return OptAddFault(Fault{Kind: KindPanic, Method: method, Probability: probability, Value: value})
+}
+
+// OptSetOnInject 设置注入故障时的回调
+func OptSetOnInject(hook func(i Injection)) Opt {
+	return func(i *Injector) {
+		i.onInject = hook
+	}
+}
+
+// Enable 启用故障注入
+func (i *Injector) Enable() {
+	atomic.StoreInt32(&i.enabled, 1)
+}
+
+// Disable 停用故障注入，停用时通知直接调用目标方法
+func (i *Injector) Disable() {
+	atomic.StoreInt32(&i.enabled, 0)
+}
+
+// Enabled 返回是否启用
+func (i *Injector) Enabled() bool {
+	return atomic.LoadInt32(&i.enabled) == 1
+}
+
+// Advice 返回故障注入通知
+func (i *Injector) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		if !i.Enabled() {
+			return invocation.Invoke(params)
+		}
+		jp := aop.JoinPointOf(invocation)
+		mt := jp.Method().Type
+		typ := jp.TargetType().String()
+		name := invocation.MethodName()
+		for _, f := range i.faults {
+			if !matches(f.Method, typ, name) {
+				continue
+			}
+			if f.Kind == KindError && !methodfunc.ReturnsError(mt) {
+				continue
+			}
+			if !i.hit(f.Probability) {
+				continue
+			}
+			if i.onInject != nil {
+				i.onInject(Injection{Type: typ, Method: name, Fault: f})
+			}
+			switch f.Kind {
+			case KindLatency:
+				i.sleep(jp.Method(), params, f.Latency)
+			case KindError:
+				err := f.Err
+				if err == nil {
+					err = ErrInjected
+				}
+				return methodfunc.ZeroResults(mt, err)
+			case KindPanic:
+				v := f.Value
+				if v == nil {
+					v = fmt.Errorf("%w: %s.%s", ErrInjected, typ, name)
+				}
+				panic(v)
+			}
+		}
+		return invocation.Invoke(params)
+	}
+}
+
+func (i *Injector) hit(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.rand.Float64() < probability
+}
+
+// sleep 延迟，方法声明了context.Context参数时在其结束时提前返回
+func (i *Injector) sleep(method reflect.Method, params []interface{}, d time.Duration) {
+	ctx, _ := methodfunc.MethodContext(method, params)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+func matches(pattern, typ, method string) bool {
+	return pattern == "*" || pattern == method || pattern == typ+"."+method
+}
@@ -0,0 +1,388 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package circuitbreaker
+
+import (
+	"container/list"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"sync"
+	"time"
+)
+
+// ErrOpen 熔断器打开时默认降级结果中的错误
+var ErrOpen = errors.New("circuitbreaker: circuit open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+const (
+	// DefaultMaxKeys 默认最多保留的熔断器数
+	DefaultMaxKeys = 10000
+	// DefaultIdleTimeout 默认的熔断器空闲淘汰时间
+	DefaultIdleTimeout = 10 * time.Minute
+)
+
+// KeyFunc 返回熔断器的key，同一key共享熔断状态
+type KeyFunc func(invocation aop.Invocation, params []interface{}) string
+
+// Fallback 熔断器打开时返回的降级结果
+type Fallback func(invocation aop.Invocation, params []interface{}) []interface{}
+
+type Breaker struct {
+	keyFunc          KeyFunc
+	fallback         Fallback
+	classifier       func(err error) bool
+	consecutive      int
+	failureRate      float64
+	window           int
+	coolDown         time.Duration
+	halfOpenMaxCalls int
+	onStateChange    func(key string, from, to State)
+	maxKeys          int
+	idleTimeout      time.Duration
+
+	lock     sync.Mutex
+	circuits map[string]*list.Element
+	// lru 按最近使用排序，队首为最近使用
+	lru *list.List
+}
+
+type circuitEntry struct {
+	key      string
+	circuit  *circuit
+	lastUsed time.Time
+}
+
+type Opt func(b *Breaker)
+
+// New 创建熔断器，默认按目标方法区分，连续失败5次打开，30秒后半开
+func New(opts ...Opt) *Breaker {
+	b := &Breaker{
+		keyFunc:          MethodKey,
+		fallback:         DefaultFallback,
+		consecutive:      5,
+		coolDown:         30 * time.Second,
+		halfOpenMaxCalls: 1,
+		classifier: func(err error) bool {
+			return err != nil
+		},
+		maxKeys:     DefaultMaxKeys,
+		idleTimeout: DefaultIdleTimeout,
+		circuits:    make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// MethodKey 以目标类型及方法名作为key
+func MethodKey(invocation aop.Invocation, params []interface{}) string {
+	return aop.JoinPointOf(invocation).TargetType().String() + "." + invocation.MethodName()
+}
+
+// DefaultFallback 按方法声明的返回值类型返回零值，最后一个返回值为error时填充ErrOpen
+func DefaultFallback(invocation aop.Invocation, params []interface{}) []interface{} {
+	return methodfunc.ZeroResults(aop.JoinPointOf(invocation).Method().Type, ErrOpen)
+}
+
+// OptSetKeyFunc 设置熔断器key，可根据参数自定义
+func OptSetKeyFunc(keyFunc KeyFunc) Opt {
+	return func(b *Breaker) {
+		b.keyFunc = keyFunc
+	}
+}
+
+// OptSetFallback 设置熔断器打开时的降级结果，默认为DefaultFallback
+func OptSetFallback(fallback Fallback) Opt {
+	return func(b *Breaker) {
+		b.fallback = fallback
+	}
+}
+
+// OptSetClassifier 设置判断调用失败的错误，默认所有错误均为失败
+func OptSetClassifier(classifier func(err error) bool) Opt {
+	return func(b *Breaker) {
+		b.classifier = classifier
+	}
+}
+
+// OptSetConsecutiveFailures 设置连续失败多少次后打开，为0时不启用
+func OptSetConsecutiveFailures(n int) Opt {
+	return func(b *Breaker) {
+		b.consecutive = n
+	}
+}
+
+// OptSetFailureRate 设置最近window次调用的失败率达到rate后打开，window为0时不启用
+func OptSetFailureRate(rate float64, window int) Opt {
+	return func(b *Breaker) {
+		b.failureRate = rate
+		b.window = window
+	}
+}
+
+// OptSetCoolDown 设置打开后转为半开的等待时间，默认为30秒
+func OptSetCoolDown(d time.Duration) Opt {
+	return func(b *Breaker) {
+		b.coolDown = d
+	}
+}
+
+// OptSetHalfOpenMaxCalls 设置半开状态允许的试探调用次数，全部成功后关闭，默认为1
+func OptSetHalfOpenMaxCalls(n int) Opt {
+	return func(b *Breaker) {
+		b.halfOpenMaxCalls = n
+	}
+}
+
+// OptSetOnStateChange 设置状态变化回调，回调执行时持有熔断器锁，不可在回调中调用Breaker.State
+func OptSetOnStateChange(hook func(key string, from, to State)) Opt {
+	return func(b *Breaker) {
+		b.onStateChange = hook
+	}
+}
+
+// OptSetMaxKeys 设置最多保留的熔断器数，超出时淘汰最久未使用且处于初始状态（关闭、没有失败记录及进行中的调用）的熔断器，
+// 小于等于0时不限制，默认为DefaultMaxKeys。按参数区分key时用于限制内存占用，
+// 所有熔断器都不处于初始状态时暂时超出该数量
+func OptSetMaxKeys(n int) Opt {
+	return func(b *Breaker) {
+		b.maxKeys = n
+	}
+}
+
+// OptSetIdleTimeout 设置熔断器空闲淘汰时间，空闲超过该时间且处于初始状态的熔断器被移除，
+// 再次使用时重新创建；小于等于0时不淘汰，默认为DefaultIdleTimeout
+func OptSetIdleTimeout(d time.Duration) Opt {
+	return func(b *Breaker) {
+		b.idleTimeout = d
+	}
+}
+
+// State 返回key对应熔断器的状态
+func (b *Breaker) State(key string) State {
+	c := b.get(key)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b.refresh(key, c, time.Now())
+	return c.state
+}
+
+// Advice 返回熔断通知，方法最后一个error返回值满足Classifier或发生panic时记为失败
+func (b *Breaker) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+		key := b.keyFunc(invocation, params)
+		c := b.get(key)
+		gen, ok := b.allow(key, c)
+		if !ok {
+			return b.fallback(invocation, params)
+		}
+		failed := true
+		defer func() {
+			b.record(key, c, gen, failed)
+		}()
+		ret = invocation.Invoke(params)
+		err := methodfunc.TrailingError(aop.JoinPointOf(invocation).Method().Type, ret)
+		failed = err != nil && b.classifier(err)
+		return ret
+	}
+}
+
+type circuit struct {
+	lock        sync.Mutex
+	state       State
+	openedAt    time.Time
+	consecutive int
+	// outcomes 最近window次调用结果的环形缓冲，true为失败
+	outcomes []bool
+	pos      int
+	count    int
+	failures int
+	// trials 半开状态已放行的试探调用次数
+	trials    int
+	successes int
+	// generation 状态变化的次数，调用结束时与放行时不一致则忽略该调用结果
+	generation uint64
+	// inflight 进行中的调用数
+	inflight int
+}
+
+func (b *Breaker) get(key string) *circuit {
+	now := time.Now()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.evictIdle(now)
+	if e, ok := b.circuits[key]; ok {
+		entry := e.Value.(*circuitEntry)
+		entry.lastUsed = now
+		b.lru.MoveToFront(e)
+		return entry.circuit
+	}
+	c := &circuit{}
+	if b.window > 0 {
+		c.outcomes = make([]bool, b.window)
+	}
+	front := b.lru.PushFront(&circuitEntry{key: key, circuit: c, lastUsed: now})
+	b.circuits[key] = front
+	// 从最久未使用的一端淘汰，跳过不处于初始状态的熔断器
+	for e := b.lru.Back(); e != front && b.maxKeys > 0 && b.lru.Len() > b.maxKeys; {
+		prev := e.Prev()
+		if e.Value.(*circuitEntry).circuit.idle() {
+			b.remove(e)
+		}
+		e = prev
+	}
+	return c
+}
+
+// evictIdle 从最久未使用的一端移除空闲超时且处于初始状态的熔断器
+func (b *Breaker) evictIdle(now time.Time) {
+	if b.idleTimeout <= 0 {
+		return
+	}
+	for e := b.lru.Back(); e != nil; {
+		entry := e.Value.(*circuitEntry)
+		if now.Sub(entry.lastUsed) < b.idleTimeout {
+			return
+		}
+		prev := e.Prev()
+		if entry.circuit.idle() {
+			b.remove(e)
+		}
+		e = prev
+	}
+}
+
+func (b *Breaker) remove(e *list.Element) {
+	b.lru.Remove(e)
+	delete(b.circuits, e.Value.(*circuitEntry).key)
+}
+
+// idle 熔断器关闭且没有失败记录及进行中的调用时返回true，此时移除后重新创建不改变熔断行为
+func (c *circuit) idle() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.state == Closed && c.consecutive == 0 && c.failures == 0 && c.inflight == 0
+}
+
+// allow 判断是否放行调用，放行时返回当前generation
+func (b *Breaker) allow(key string, c *circuit) (uint64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b.refresh(key, c, time.Now())
+	switch c.state {
+	case Open:
+		return 0, false
+	case HalfOpen:
+		if c.trials >= b.halfOpenMaxCalls {
+			return 0, false
+		}
+		c.trials++
+	}
+	c.inflight++
+	return c.generation, true
+}
+
+// record 记录调用结果，放行后状态已变化时忽略，如关闭时放行的慢调用在半开状态结束
+func (b *Breaker) record(key string, c *circuit, gen uint64, failed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.inflight--
+	if gen != c.generation {
+		return
+	}
+	switch c.state {
+	case HalfOpen:
+		if failed {
+			b.transit(key, c, Open)
+			return
+		}
+		c.successes++
+		if c.successes >= b.halfOpenMaxCalls {
+			b.transit(key, c, Closed)
+		}
+	case Closed:
+		if failed {
+			c.consecutive++
+		} else {
+			c.consecutive = 0
+		}
+		if c.outcomes != nil {
+			if c.count == len(c.outcomes) {
+				if c.outcomes[c.pos] {
+					c.failures--
+				}
+			} else {
+				c.count++
+			}
+			c.outcomes[c.pos] = failed
+			if failed {
+				c.failures++
+			}
+			c.pos = (c.pos + 1) % len(c.outcomes)
+		}
+		if b.consecutive > 0 && c.consecutive >= b.consecutive {
+			b.transit(key, c, Open)
+		} else if c.outcomes != nil && c.count == len(c.outcomes) &&
+			float64(c.failures)/float64(c.count) >= b.failureRate {
+			b.transit(key, c, Open)
+		}
+	}
+}
+
+// refresh 打开状态超过冷却时间后转为半开
+func (b *Breaker) refresh(key string, c *circuit, now time.Time) {
+	if c.state == Open && now.Sub(c.openedAt) >= b.coolDown {
+		b.transit(key, c, HalfOpen)
+	}
+}
+
+func (b *Breaker) transit(key string, c *circuit, to State) {
+	from := c.state
+	c.state = to
+	c.generation++
+	c.consecutive = 0
+	c.trials = 0
+	c.successes = 0
+	c.pos, c.count, c.failures = 0, 0, 0
+	if to == Open {
+		c.openedAt = time.Now()
+	}
+	if b.onStateChange != nil && from != to {
+		b.onStateChange(key, from, to)
+	}
+}
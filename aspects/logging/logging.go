@@ -0,0 +1,188 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Record 一次方法调用的日志记录
+type Record struct {
+	Context  context.Context
+	Time     time.Time
+	Level    slog.Level
+	Type     string
+	Method   string
+	Args     []interface{}
+	Results  []interface{}
+	Duration time.Duration
+	// Err 方法最后一个error返回值或panic
+	Err error
+}
+
+type Sink interface {
+	// Write 输出日志记录
+	Write(r *Record)
+}
+
+type logger struct {
+	sink         Sink
+	level        slog.Level
+	errorLevel   slog.Level
+	minLevel     slog.Level
+	methodLevels map[string]slog.Level
+	sensitive    map[string]map[int]bool
+}
+
+type Opt func(l *logger)
+
+// New 创建日志通知，默认以JSON lines格式输出至标准输出
+func New(opts ...Opt) aop.Advice {
+	l := &logger{
+		sink:         NewJSONSink(os.Stdout),
+		level:        slog.LevelInfo,
+		errorLevel:   slog.LevelError,
+		minLevel:     slog.LevelDebug,
+		methodLevels: make(map[string]slog.Level),
+		sensitive:    make(map[string]map[int]bool),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l.advice
+}
+
+// OptSetSink 设置日志输出
+func OptSetSink(sink Sink) Opt {
+	return func(l *logger) {
+		l.sink = sink
+	}
+}
+
+// OptSetLevel 设置调用成功时的日志级别，默认为Info
+func OptSetLevel(level slog.Level) Opt {
+	return func(l *logger) {
+		l.level = level
+	}
+}
+
+// OptSetErrorLevel 设置调用返回error或panic时的日志级别，默认为Error
+func OptSetErrorLevel(level slog.Level) Opt {
+	return func(l *logger) {
+		l.errorLevel = level
+	}
+}
+
+// OptSetMinLevel 设置最低输出级别，低于该级别的日志不输出，默认为Debug
+func OptSetMinLevel(level slog.Level) Opt {
+	return func(l *logger) {
+		l.minLevel = level
+	}
+}
+
+// OptSetMethodLevel 设置指定方法调用成功时的日志级别
+func OptSetMethodLevel(method string, level slog.Level) Opt {
+	return func(l *logger) {
+		l.methodLevels[method] = level
+	}
+}
+
+// OptSetSensitiveParams 标记指定方法的敏感参数，输出时替换为Redacted
+// method： 方法名
+// indexes： 参数位置
+func OptSetSensitiveParams(method string, indexes ...int) Opt {
+	return func(l *logger) {
+		m, ok := l.sensitive[method]
+		if !ok {
+			m = make(map[int]bool)
+			l.sensitive[method] = m
+		}
+		for _, i := range indexes {
+			m[i] = true
+		}
+	}
+}
+
+func (l *logger) advice(invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+	jp := aop.JoinPointOf(invocation)
+	method := jp.Method()
+	r := &Record{
+		Time:   time.Now(),
+		Type:   jp.TargetType().String(),
+		Method: method.Name,
+		Args:   l.redactArgs(method.Name, params),
+	}
+	r.Context, _ = methodfunc.FindContext(params)
+	defer func() {
+		r.Duration = time.Since(r.Time)
+		if o := recover(); o != nil {
+			r.Err = fmt.Errorf("panic: %v", o)
+			l.write(r)
+			panic(o)
+		}
+		r.Results = l.redactResults(ret)
+		r.Err = methodfunc.TrailingError(method.Type, ret)
+		l.write(r)
+	}()
+	return invocation.Invoke(params)
+}
+
+func (l *logger) write(r *Record) {
+	level, ok := l.methodLevels[r.Method]
+	if !ok {
+		level = l.level
+	}
+	if r.Err != nil && l.errorLevel > level {
+		level = l.errorLevel
+	}
+	if level < l.minLevel {
+		return
+	}
+	r.Level = level
+	l.sink.Write(r)
+}
+
+func (l *logger) redactResults(ret []interface{}) []interface{} {
+	if ret == nil {
+		return nil
+	}
+	results := make([]interface{}, len(ret))
+	for i, v := range ret {
+		results[i] = Redact(v)
+	}
+	return results
+}
+
+func (l *logger) redactArgs(method string, params []interface{}) []interface{} {
+	sensitive := l.sensitive[method]
+	ret := make([]interface{}, len(params))
+	for i, p := range params {
+		if sensitive[i] {
+			ret[i] = Redacted
+		} else {
+			ret[i] = Redact(p)
+		}
+	}
+	return ret
+}
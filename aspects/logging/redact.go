@@ -0,0 +1,190 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// Redacted 敏感数据替换值
+	Redacted = "******"
+
+	// TagName 结构体字段标记为`log:"sensitive"`时输出替换为Redacted
+	TagName = "log"
+)
+
+// maxRedactDepth 脱敏时展开的最大层数，防止递归类型无限展开，超过时整体替换为Redacted
+const maxRedactDepth = 8
+
+// Redact 返回脱敏后的值：包含敏感字段的结构体（或其指针）转换为以字段名为key的map，
+// 敏感字段替换为Redacted；元素包含敏感字段的slice、array转换为[]interface{}，
+// map转换为map[string]interface{}（key以fmt格式化）；其他值原样返回。
+// interface类型（如[]interface{}的元素、error字段）按其动态类型判断
+func Redact(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if !valueSensitive(rv, 0) {
+		return v
+	}
+	return redactValue(rv, 0)
+}
+
+func redact(rv reflect.Value, depth int) interface{} {
+	if depth > maxRedactDepth {
+		return Redacted
+	}
+	if !valueSensitive(rv, depth) {
+		return rv.Interface()
+	}
+	return redactValue(rv, depth)
+}
+
+func redactValue(rv reflect.Value, depth int) interface{} {
+	if depth > maxRedactDepth {
+		return Redacted
+	}
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		ret := make([]interface{}, rv.Len())
+		for i := range ret {
+			ret[i] = redact(rv.Index(i), depth+1)
+		}
+		return ret
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		ret := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			ret[fmt.Sprint(redact(iter.Key(), depth+1))] = redact(iter.Value(), depth+1)
+		}
+		return ret
+	case reflect.Struct:
+	default:
+		return rv.Interface()
+	}
+	t := rv.Type()
+	ret := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if isSensitive(f) {
+			ret[f.Name] = Redacted
+		} else {
+			ret[f.Name] = redact(rv.Field(i), depth+1)
+		}
+	}
+	return ret
+}
+
+func isSensitive(f reflect.StructField) bool {
+	for _, v := range strings.Split(f.Tag.Get(TagName), ",") {
+		if strings.TrimSpace(v) == "sensitive" {
+			return true
+		}
+	}
+	return false
+}
+
+// valueSensitive 判断值是否包含敏感字段，interface按动态类型判断
+func valueSensitive(rv reflect.Value, depth int) bool {
+	if depth > maxRedactDepth || !rv.IsValid() {
+		return false
+	}
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+	if !maySensitive(rv.Type(), 0) {
+		return false
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if valueSensitive(rv.Index(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if valueSensitive(iter.Key(), depth+1) || valueSensitive(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if isSensitive(f) || valueSensitive(rv.Field(i), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maySensitive 判断类型是否可能包含敏感字段（包含敏感字段或interface），用于跳过无需遍历的值
+func maySensitive(t reflect.Type, depth int) bool {
+	if depth > maxRedactDepth {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array:
+		return maySensitive(t.Elem(), depth+1)
+	case reflect.Map:
+		return maySensitive(t.Key(), depth+1) || maySensitive(t.Elem(), depth+1)
+	case reflect.Struct:
+	default:
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if isSensitive(f) || maySensitive(f.Type, depth+1) {
+			return true
+		}
+	}
+	return false
+}
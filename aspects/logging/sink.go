@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type slogSink struct {
+	logger *slog.Logger
+	msg    string
+}
+
+// NewSlogSink 使用log/slog输出日志记录
+func NewSlogSink(logger *slog.Logger) *slogSink {
+	return &slogSink{
+		logger: logger,
+		msg:    "aop call",
+	}
+}
+
+func (s *slogSink) Write(r *Record) {
+	attrs := []slog.Attr{
+		slog.String("type", r.Type),
+		slog.String("method", r.Method),
+		slog.Any("args", r.Args),
+		slog.Any("results", r.Results),
+		slog.Duration("duration", r.Duration),
+	}
+	if r.Err != nil {
+		attrs = append(attrs, slog.String("error", r.Err.Error()))
+	}
+	s.logger.LogAttrs(r.Context, r.Level, s.msg, attrs...)
+}
+
+type jsonRecord struct {
+	Time     string            `json:"time"`
+	Level    string            `json:"level"`
+	Type     string            `json:"type"`
+	Method   string            `json:"method"`
+	Args     []json.RawMessage `json:"args"`
+	Results  []json.RawMessage `json:"results"`
+	Duration int64             `json:"duration_ns"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type jsonSink struct {
+	w    io.Writer
+	lock sync.Mutex
+}
+
+// NewJSONSink 以JSON lines格式输出日志记录
+func NewJSONSink(w io.Writer) *jsonSink {
+	return &jsonSink{w: w}
+}
+
+func (s *jsonSink) Write(r *Record) {
+	jr := jsonRecord{
+		Time:     r.Time.Format(time.RFC3339Nano),
+		Level:    r.Level.String(),
+		Type:     r.Type,
+		Method:   r.Method,
+		Args:     marshalValues(r.Args),
+		Results:  marshalValues(r.Results),
+		Duration: int64(r.Duration),
+	}
+	if r.Err != nil {
+		jr.Error = r.Err.Error()
+	}
+	b, err := json.Marshal(jr)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.w.Write(b)
+}
+
+// marshalValues 无法序列化的值以fmt格式的字符串输出
+func marshalValues(values []interface{}) []json.RawMessage {
+	ret := make([]json.RawMessage, len(values))
+	for i, v := range values {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			b, _ = json.Marshal(fmt.Sprintf("%v", v))
+		}
+		ret[i] = b
+	}
+	return ret
+}
+
+type logfmtSink struct {
+	w    io.Writer
+	lock sync.Mutex
+}
+
+// NewLogfmtSink 以logfmt格式输出日志记录
+func NewLogfmtSink(w io.Writer) *logfmtSink {
+	return &logfmtSink{w: w}
+}
+
+func (s *logfmtSink) Write(r *Record) {
+	buf := strings.Builder{}
+	writeLogfmt(&buf, "time", r.Time.Format(time.RFC3339Nano))
+	writeLogfmt(&buf, "level", r.Level.String())
+	writeLogfmt(&buf, "type", r.Type)
+	writeLogfmt(&buf, "method", r.Method)
+	writeLogfmt(&buf, "args", fmt.Sprintf("%v", r.Args))
+	writeLogfmt(&buf, "results", fmt.Sprintf("%v", r.Results))
+	writeLogfmt(&buf, "duration", r.Duration.String())
+	if r.Err != nil {
+		writeLogfmt(&buf, "error", r.Err.Error())
+	}
+	buf.WriteByte('\n')
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	io.WriteString(s.w, buf.String())
+}
+
+func writeLogfmt(buf *strings.Builder, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		buf.WriteString(strconv.Quote(value))
+	} else {
+		buf.WriteString(value)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type snapshot struct {
+	key
+	calls    uint64
+	errors   uint64
+	inFlight int64
+	buckets  []uint64
+	sum      float64
+}
+
+// Handler 返回以Prometheus文本格式输出指标的http.Handler
+func (r *Registry) Handler() http.Handler {
+	return r
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.WriteTo(w)
+}
+
+// WriteTo 以Prometheus文本格式输出指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	snaps := r.snapshot()
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	name := r.namespace + "_calls_total"
+	writeHeader(bw, name, "counter", "Total number of proxied method calls.")
+	for _, s := range snaps {
+		fmt.Fprintf(bw, "%s%s %d\n", name, labels(s.key, ""), s.calls)
+	}
+
+	name = r.namespace + "_errors_total"
+	writeHeader(bw, name, "counter", "Total number of proxied method calls returning an error or panicking.")
+	for _, s := range snaps {
+		fmt.Fprintf(bw, "%s%s %d\n", name, labels(s.key, ""), s.errors)
+	}
+
+	name = r.namespace + "_in_flight"
+	writeHeader(bw, name, "gauge", "Number of proxied method calls in flight.")
+	for _, s := range snaps {
+		fmt.Fprintf(bw, "%s%s %d\n", name, labels(s.key, ""), s.inFlight)
+	}
+
+	name = r.namespace + "_call_duration_seconds"
+	writeHeader(bw, name, "histogram", "Latency of proxied method calls in seconds.")
+	for _, s := range snaps {
+		var count uint64
+		for i, b := range r.buckets {
+			count += s.buckets[i]
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", name, labels(s.key, formatFloat(b)), count)
+		}
+		count += s.buckets[len(r.buckets)]
+		fmt.Fprintf(bw, "%s_bucket%s %d\n", name, labels(s.key, "+Inf"), count)
+		fmt.Fprintf(bw, "%s_sum%s %s\n", name, labels(s.key, ""), formatFloat(s.sum))
+		fmt.Fprintf(bw, "%s_count%s %d\n", name, labels(s.key, ""), count)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func (r *Registry) snapshot() []snapshot {
+	r.lock.RLock()
+	ret := make([]snapshot, 0, len(r.methods))
+	for k, m := range r.methods {
+		m.lock.Lock()
+		ret = append(ret, snapshot{
+			key:      k,
+			calls:    m.calls,
+			errors:   m.errors,
+			inFlight: m.inFlight,
+			buckets:  append([]uint64(nil), m.buckets...),
+			sum:      m.sum,
+		})
+		m.lock.Unlock()
+	}
+	r.lock.RUnlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].typ != ret[j].typ {
+			return ret[i].typ < ret[j].typ
+		}
+		return ret[i].method < ret[j].method
+	})
+	return ret
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func labels(k key, le string) string {
+	buf := strings.Builder{}
+	buf.WriteString(`{type="`)
+	buf.WriteString(escape(k.typ))
+	buf.WriteString(`",method="`)
+	buf.WriteString(escape(k.method))
+	buf.WriteString(`"`)
+	if le != "" {
+		buf.WriteString(`,le="`)
+		buf.WriteString(le)
+		buf.WriteString(`"`)
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
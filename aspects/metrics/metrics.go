@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"sort"
+	"sync"
+	"time"
+)
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type key struct {
+	typ    string
+	method string
+}
+
+type methodMetrics struct {
+	lock     sync.Mutex
+	calls    uint64
+	errors   uint64
+	inFlight int64
+	// buckets 各区间（非累计）的调用次数，最后一个为+Inf
+	buckets []uint64
+	sum     float64
+}
+
+type Registry struct {
+	namespace string
+	buckets   []float64
+
+	lock    sync.RWMutex
+	methods map[key]*methodMetrics
+}
+
+type Opt func(r *Registry)
+
+// NewRegistry 创建进程内指标注册表
+func NewRegistry(opts ...Opt) *Registry {
+	r := &Registry{
+		namespace: "aop",
+		buckets:   DefaultBuckets,
+		methods:   make(map[key]*methodMetrics),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// OptSetNamespace 设置指标名前缀，默认为aop
+func OptSetNamespace(namespace string) Opt {
+	return func(r *Registry) {
+		r.namespace = namespace
+	}
+}
+
+// OptSetBuckets 设置耗时直方图区间上限（单位：秒），默认为DefaultBuckets
+func OptSetBuckets(buckets ...float64) Opt {
+	return func(r *Registry) {
+		b := append([]float64(nil), buckets...)
+		sort.Float64s(b)
+		r.buckets = b
+	}
+}
+
+// Advice 返回记录指标的通知
+func (r *Registry) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+		m := r.get(aop.JoinPointOf(invocation).TargetType().String(), invocation.MethodName())
+		m.lock.Lock()
+		m.inFlight++
+		m.lock.Unlock()
+
+		start := time.Now()
+		failed := true
+		defer func() {
+			r.observe(m, time.Since(start), failed)
+		}()
+		ret = invocation.Invoke(params)
+		failed = methodfunc.TrailingError(aop.JoinPointOf(invocation).Method().Type, ret) != nil
+		return ret
+	}
+}
+
+func (r *Registry) get(typ, method string) *methodMetrics {
+	k := key{typ: typ, method: method}
+	r.lock.RLock()
+	m, ok := r.methods[k]
+	r.lock.RUnlock()
+	if ok {
+		return m
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if m, ok = r.methods[k]; !ok {
+		m = &methodMetrics{buckets: make([]uint64, len(r.buckets)+1)}
+		r.methods[k] = m
+	}
+	return m
+}
+
+func (r *Registry) observe(m *methodMetrics, d time.Duration, failed bool) {
+	sec := d.Seconds()
+	i := sort.SearchFloat64s(r.buckets, sec)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.inFlight--
+	m.calls++
+	if failed {
+		m.errors++
+	}
+	m.buckets[i]++
+	m.sum += sec
+}
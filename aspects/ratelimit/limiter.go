@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type Limiter interface {
+	// TryAcquire 尝试获取一个许可
+	// ok： 获取成功返回true
+	// wait： 获取失败时距离下一个许可可用的时间
+	TryAcquire(now time.Time) (ok bool, wait time.Duration)
+}
+
+// IdleLimiter 可选接口，报告Limiter是否已恢复初始状态。
+// 未恢复初始状态的Limiter不会被淘汰，避免重新创建后放行本应拒绝的调用
+type IdleLimiter interface {
+	// Idle 返回now时Limiter是否与新创建的Limiter等价
+	Idle(now time.Time) bool
+}
+
+// LimiterFactory 为每个key创建独立的Limiter
+type LimiterFactory func() Limiter
+
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 创建令牌桶限流器，burst小于1时panic（否则永远无法获取许可）
+// rate： 每秒生成的令牌数
+// burst： 桶容量，即允许的突发调用数
+func NewTokenBucket(rate float64, burst int) Limiter {
+	checkBurst(burst)
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// TokenBucket 返回创建令牌桶限流器的LimiterFactory，burst小于1时panic
+func TokenBucket(rate float64, burst int) LimiterFactory {
+	checkBurst(burst)
+	return func() Limiter {
+		return NewTokenBucket(rate, burst)
+	}
+}
+
+func (l *tokenBucket) TryAcquire(now time.Time) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !l.last.IsZero() {
+		elapsed := now.Sub(l.last).Seconds()
+		if elapsed > 0 {
+			l.tokens = math.Min(l.burst, l.tokens+elapsed*l.rate)
+		}
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	if l.rate <= 0 {
+		return false, time.Duration(math.MaxInt64)
+	}
+	return false, time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Idle 令牌桶已满时返回true
+func (l *tokenBucket) Idle(now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.last.IsZero() {
+		return true
+	}
+	return l.tokens+now.Sub(l.last).Seconds()*l.rate >= l.burst
+}
+
+func checkBurst(burst int) {
+	if burst < 1 {
+		panic(fmt.Errorf("ratelimit: token bucket burst must be at least 1, got %d", burst))
+	}
+}
+
+func checkLimit(limit int) {
+	if limit < 1 {
+		panic(fmt.Errorf("ratelimit: sliding window limit must be at least 1, got %d", limit))
+	}
+}
+
+type slidingWindow struct {
+	lock   sync.Mutex
+	window time.Duration
+	// times 窗口内已放行调用的时间，环形缓冲
+	times []time.Time
+	head  int
+	count int
+}
+
+// NewSlidingWindow 创建滑动窗口限流器，任意window时长内最多放行limit次调用，limit小于1时panic
+func NewSlidingWindow(limit int, window time.Duration) Limiter {
+	checkLimit(limit)
+	return &slidingWindow{
+		window: window,
+		times:  make([]time.Time, limit),
+	}
+}
+
+// SlidingWindow 返回创建滑动窗口限流器的LimiterFactory，limit小于1时panic
+func SlidingWindow(limit int, window time.Duration) LimiterFactory {
+	checkLimit(limit)
+	return func() Limiter {
+		return NewSlidingWindow(limit, window)
+	}
+}
+
+func (l *slidingWindow) TryAcquire(now time.Time) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for l.count > 0 && now.Sub(l.times[l.head]) >= l.window {
+		l.head = (l.head + 1) % len(l.times)
+		l.count--
+	}
+	if l.count < len(l.times) {
+		l.times[(l.head+l.count)%len(l.times)] = now
+		l.count++
+		return true, 0
+	}
+	return false, l.times[l.head].Add(l.window).Sub(now)
+}
+
+// Idle 窗口内没有已放行的调用时返回true
+func (l *slidingWindow) Idle(now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.count == 0 {
+		return true
+	}
+	last := l.times[(l.head+l.count-1)%len(l.times)]
+	return now.Sub(last) >= l.window
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited 调用被限流，可通过errors.Is(err, ErrRateLimited)判断
+var ErrRateLimited = errors.New("ratelimit: rate limited")
+
+type RateLimitError struct {
+	Key string
+	// RetryAfter 距离下一个许可可用的时间
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("ratelimit: %s rate limited, retry after %s", e.Key, e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// KeyFunc 返回限流的key，同一key共享Limiter
+type KeyFunc func(invocation aop.Invocation, params []interface{}) string
+
+const (
+	// DefaultMaxKeys 默认最多保留的Limiter数
+	DefaultMaxKeys = 10000
+	// DefaultIdleTimeout 默认的Limiter空闲淘汰时间
+	DefaultIdleTimeout = 10 * time.Minute
+)
+
+type limiterEntry struct {
+	key      string
+	limiter  Limiter
+	lastUsed time.Time
+}
+
+type rateLimiter struct {
+	factory     LimiterFactory
+	keyFunc     KeyFunc
+	blocking    bool
+	maxKeys     int
+	idleTimeout time.Duration
+
+	lock     sync.Mutex
+	limiters map[string]*list.Element
+	// lru 按最近使用排序，队首为最近使用
+	lru *list.List
+}
+
+type Opt func(r *rateLimiter)
+
+// New 创建限流通知，默认按目标方法区分且超过限制时立即拒绝：
+// 方法最后一个返回值为error时返回*RateLimitError，否则以*RateLimitError panic
+// factory： 为每个key创建Limiter，如TokenBucket(10, 10)
+func New(factory LimiterFactory, opts ...Opt) aop.Advice {
+	r := &rateLimiter{
+		factory:     factory,
+		keyFunc:     MethodKey,
+		maxKeys:     DefaultMaxKeys,
+		idleTimeout: DefaultIdleTimeout,
+		limiters:    make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r.advice
+}
+
+// MethodKey 以目标类型及方法名作为key
+func MethodKey(invocation aop.Invocation, params []interface{}) string {
+	return aop.JoinPointOf(invocation).TargetType().String() + "." + invocation.MethodName()
+}
+
+// OptSetKeyFunc 设置限流key，可根据参数区分，如按收件人限流
+func OptSetKeyFunc(keyFunc KeyFunc) Opt {
+	return func(r *rateLimiter) {
+		r.keyFunc = keyFunc
+	}
+}
+
+// OptSetMaxKeys 设置最多保留的Limiter数，超出时淘汰最久未使用且已恢复初始状态（见IdleLimiter）的Limiter，
+// 小于等于0时不限制，默认为DefaultMaxKeys。按参数区分key时用于限制内存占用，
+// 所有Limiter都未恢复初始状态时暂时超出该数量
+func OptSetMaxKeys(n int) Opt {
+	return func(r *rateLimiter) {
+		r.maxKeys = n
+	}
+}
+
+// OptSetIdleTimeout 设置Limiter空闲淘汰时间，空闲超过该时间且已恢复初始状态（见IdleLimiter）的Limiter被移除，
+// 再次使用时重新创建；小于等于0时不淘汰，默认为DefaultIdleTimeout
+func OptSetIdleTimeout(d time.Duration) Opt {
+	return func(r *rateLimiter) {
+		r.idleTimeout = d
+	}
+}
+
+// OptSetBlocking 设置超过限制时阻塞等待许可；方法参数包含context.Context时，
+// context结束后停止等待并返回context的错误
+func OptSetBlocking(blocking bool) Opt {
+	return func(r *rateLimiter) {
+		r.blocking = blocking
+	}
+}
+
+func (r *rateLimiter) advice(invocation aop.Invocation, params []interface{}) []interface{} {
+	key := r.keyFunc(invocation, params)
+	l := r.get(key)
+	if err := r.acquire(key, l, aop.JoinPointOf(invocation).Method(), params); err != nil {
+		return methodfunc.ErrorResults(aop.JoinPointOf(invocation).Method().Type, err)
+	}
+	return invocation.Invoke(params)
+}
+
+func (r *rateLimiter) acquire(key string, l Limiter, method reflect.Method, params []interface{}) error {
+	ok, wait := l.TryAcquire(time.Now())
+	if ok {
+		return nil
+	}
+	if !r.blocking {
+		return &RateLimitError{Key: key, RetryAfter: wait}
+	}
+	ctx, _ := methodfunc.MethodContext(method, params)
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if ok, wait = l.TryAcquire(time.Now()); ok {
+			return nil
+		}
+	}
+}
+
+func (r *rateLimiter) get(key string) Limiter {
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.evictIdle(now)
+	if e, ok := r.limiters[key]; ok {
+		entry := e.Value.(*limiterEntry)
+		entry.lastUsed = now
+		r.lru.MoveToFront(e)
+		return entry.limiter
+	}
+	entry := &limiterEntry{key: key, limiter: r.factory(), lastUsed: now}
+	front := r.lru.PushFront(entry)
+	r.limiters[key] = front
+	// 从最久未使用的一端淘汰，跳过未恢复初始状态的Limiter
+	for e := r.lru.Back(); e != front && r.maxKeys > 0 && r.lru.Len() > r.maxKeys; {
+		prev := e.Prev()
+		if evictable(e.Value.(*limiterEntry).limiter, now) {
+			r.remove(e)
+		}
+		e = prev
+	}
+	return entry.limiter
+}
+
+// evictIdle 从最久未使用的一端移除空闲超时且已恢复初始状态的Limiter
+func (r *rateLimiter) evictIdle(now time.Time) {
+	if r.idleTimeout <= 0 {
+		return
+	}
+	for e := r.lru.Back(); e != nil; {
+		entry := e.Value.(*limiterEntry)
+		if now.Sub(entry.lastUsed) < r.idleTimeout {
+			return
+		}
+		prev := e.Prev()
+		if evictable(entry.limiter, now) {
+			r.remove(e)
+		}
+		e = prev
+	}
+}
+
+// evictable 未实现IdleLimiter的Limiter总是可以淘汰
+func evictable(l Limiter, now time.Time) bool {
+	if il, ok := l.(IdleLimiter); ok {
+		return il.Idle(now)
+	}
+	return true
+}
+
+func (r *rateLimiter) remove(e *list.Element) {
+	r.lru.Remove(e)
+	delete(r.limiters, e.Value.(*limiterEntry).key)
+}
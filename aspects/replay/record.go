@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"os"
+	"sync"
+)
+
+// Entry 一次调用的记录，参数不包含context.Context，结果不包含最后一个error返回值
+type Entry struct {
+	Type    string            `json:"type"`
+	Method  string            `json:"method"`
+	Args    []json.RawMessage `json:"args"`
+	Results []json.RawMessage `json:"results"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Recorder 记录经过代理的真实调用，用于生成golden文件
+type Recorder struct {
+	lock    sync.Mutex
+	entries []Entry
+	errs    []error
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Advice 返回记录通知，目标方法正常调用，参数及结果以JSON格式记录（结构体未导出字段不记录）。
+// 参数在调用前记录；参数或结果无法编码时不记录该调用，也不影响调用本身，错误由Err及Save返回
+func (r *Recorder) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		e := Entry{
+			Type:   aop.JoinPointOf(invocation).TargetType().String(),
+			Method: invocation.MethodName(),
+		}
+		args, argsErr := encodeArgs(params)
+		ret := invocation.Invoke(params)
+		if argsErr != nil {
+			r.fail(fmt.Errorf("replay: record %s.%s args: %w", e.Type, e.Method, argsErr))
+			return ret
+		}
+		e.Args = args
+		results := ret
+		if methodfunc.ReturnsError(aop.JoinPointOf(invocation).Method().Type) && len(ret) > 0 {
+			if err, _ := ret[len(ret)-1].(error); err != nil {
+				e.Error = err.Error()
+			}
+			results = ret[:len(ret)-1]
+		}
+		var err error
+		if e.Results, err = encode(results); err != nil {
+			r.fail(fmt.Errorf("replay: record %s.%s results: %w", e.Type, e.Method, err))
+			return ret
+		}
+		r.lock.Lock()
+		r.entries = append(r.entries, e)
+		r.lock.Unlock()
+		return ret
+	}
+}
+
+func (r *Recorder) fail(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+// Err 返回记录失败（参数或结果无法编码）的错误，没有失败时返回nil
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return errors.Join(r.errs...)
+}
+
+// Entries 返回已记录的调用，按调用结束顺序排列
+func (r *Recorder) Entries() []Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Save 将已记录的调用写入golden文件，存在记录失败的调用时不写入并返回Err
+func (r *Recorder) Save(path string) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(r.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+func encodeArgs(params []interface{}) ([]json.RawMessage, error) {
+	args := make([]interface{}, 0, len(params))
+	for _, p := range params {
+		if _, ok := p.(context.Context); ok {
+			continue
+		}
+		args = append(args, p)
+	}
+	return encode(args)
+}
+
+func encode(values []interface{}) ([]json.RawMessage, error) {
+	ret := make([]json.RawMessage, len(values))
+	for i, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = b
+	}
+	return ret, nil
+}
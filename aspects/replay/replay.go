@@ -0,0 +1,252 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrNoMatch 回放时没有匹配的记录
+var ErrNoMatch = errors.New("replay: no recorded call matches")
+
+type Mode int
+
+const (
+	// Strict 调用顺序及参数必须与记录完全一致，每条记录只回放一次
+	Strict Mode = iota
+	// Lenient 忽略调用顺序，按类型、方法及参数匹配，记录可重复回放
+	Lenient
+)
+
+// MismatchError 回放时没有匹配的记录，Diff为与最接近的记录的差异
+type MismatchError struct {
+	Type   string
+	Method string
+	Args   []json.RawMessage
+	Diff   string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("replay: no recorded call matches %s.%s(%s)\n%s", e.Type, e.Method, joinArgs(e.Args), e.Diff)
+}
+
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrNoMatch
+}
+
+type Player struct {
+	mode    Mode
+	entries []Entry
+
+	lock sync.Mutex
+	next int
+	used []bool
+}
+
+type Opt func(p *Player)
+
+// Load 读取golden文件创建回放器
+func Load(path string, opts ...Opt) (*Player, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("replay: parse %s: %w", path, err)
+	}
+	return NewPlayer(entries, opts...), nil
+}
+
+// NewPlayer 使用已记录的调用创建回放器
+func NewPlayer(entries []Entry, opts ...Opt) *Player {
+	p := &Player{
+		mode:    Strict,
+		entries: entries,
+		used:    make([]bool, len(entries)),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// OptSetMode 设置匹配模式，默认为Strict
+func OptSetMode(mode Mode) Opt {
+	return func(p *Player) {
+		p.mode = mode
+	}
+}
+
+// Advice 返回回放通知，不调用目标方法，返回匹配记录的结果；记录的error以errors.New(消息)还原。
+// 没有匹配的记录时，方法最后一个返回值为error则返回*MismatchError，否则以其panic
+func (p *Player) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		mt := aop.JoinPointOf(invocation).Method().Type
+		args, err := encodeArgs(params)
+		if err != nil {
+			return methodfunc.ErrorResults(mt, fmt.Errorf("replay: encode %s args: %w", invocation.MethodName(), err))
+		}
+		e, err := p.match(aop.JoinPointOf(invocation).TargetType().String(), invocation.MethodName(), args)
+		if err != nil {
+			return methodfunc.ErrorResults(mt, err)
+		}
+		ret, err := decodeResults(mt, e)
+		if err != nil {
+			return methodfunc.ErrorResults(mt, err)
+		}
+		return ret
+	}
+}
+
+// Verify Strict模式下存在未回放的记录时返回错误
+func (p *Player) Verify() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var missing []string
+	for i, e := range p.entries {
+		if !p.used[i] {
+			missing = append(missing, fmt.Sprintf("  #%d %s.%s(%s)", i, e.Type, e.Method, joinArgs(e.Args)))
+		}
+	}
+	if p.mode == Strict && len(missing) > 0 {
+		return fmt.Errorf("replay: %d recorded calls not replayed:\n%s", len(missing), strings.Join(missing, "\n"))
+	}
+	return nil
+}
+
+func (p *Player) match(typ, method string, args []json.RawMessage) (*Entry, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.mode == Strict {
+		if p.next >= len(p.entries) {
+			return nil, &MismatchError{Type: typ, Method: method, Args: args, Diff: fmt.Sprintf("all %d recorded calls already replayed", len(p.entries))}
+		}
+		e := &p.entries[p.next]
+		if diff := diffEntry(e, typ, method, args); diff != "" {
+			return nil, &MismatchError{Type: typ, Method: method, Args: args, Diff: fmt.Sprintf("recorded call #%d:\n%s", p.next, diff)}
+		}
+		p.used[p.next] = true
+		p.next++
+		return e, nil
+	}
+
+	var diffs []string
+	for i := range p.entries {
+		e := &p.entries[i]
+		diff := diffEntry(e, typ, method, args)
+		if diff == "" {
+			p.used[i] = true
+			return e, nil
+		}
+		if e.Type == typ && e.Method == method {
+			diffs = append(diffs, fmt.Sprintf("recorded call #%d:\n%s", i, diff))
+		}
+	}
+	if len(diffs) == 0 {
+		diffs = append(diffs, "no recorded call of this method")
+	}
+	return nil, &MismatchError{Type: typ, Method: method, Args: args, Diff: strings.Join(diffs, "\n")}
+}
+
+// diffEntry 返回记录与调用的差异，一致时返回空字符串
+func diffEntry(e *Entry, typ, method string, args []json.RawMessage) string {
+	buf := strings.Builder{}
+	if e.Type != typ || e.Method != method {
+		fmt.Fprintf(&buf, "  - %s.%s(%s)\n  + %s.%s(%s)\n", e.Type, e.Method, joinArgs(e.Args), typ, method, joinArgs(args))
+		return buf.String()
+	}
+	n := len(e.Args)
+	if len(args) > n {
+		n = len(args)
+	}
+	for i := 0; i < n; i++ {
+		var recorded, actual json.RawMessage
+		if i < len(e.Args) {
+			recorded = e.Args[i]
+		}
+		if i < len(args) {
+			actual = args[i]
+		}
+		if !jsonEqual(recorded, actual) {
+			fmt.Fprintf(&buf, "  arg[%d]:\n  - %s\n  + %s\n", i, orMissing(recorded), orMissing(actual))
+		}
+	}
+	return buf.String()
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func decodeResults(funcType reflect.Type, e *Entry) ([]interface{}, error) {
+	n := funcType.NumOut()
+	returnsError := methodfunc.ReturnsError(funcType)
+	if returnsError {
+		n--
+	}
+	if len(e.Results) != n {
+		return nil, fmt.Errorf("replay: %s.%s recorded %d results, method returns %d", e.Type, e.Method, len(e.Results), n)
+	}
+	ret := make([]interface{}, 0, funcType.NumOut())
+	for i := 0; i < n; i++ {
+		v := reflect.New(funcType.Out(i))
+		if err := json.Unmarshal(e.Results[i], v.Interface()); err != nil {
+			return nil, fmt.Errorf("replay: decode %s.%s result %d: %w", e.Type, e.Method, i, err)
+		}
+		ret = append(ret, v.Elem().Interface())
+	}
+	if returnsError {
+		var err error
+		if e.Error != "" {
+			err = errors.New(e.Error)
+		}
+		ret = append(ret, err)
+	}
+	return ret, nil
+}
+
+func joinArgs(args []json.RawMessage) string {
+	s := make([]string, len(args))
+	for i, a := range args {
+		s[i] = string(a)
+	}
+	return strings.Join(s, ", ")
+}
+
+func orMissing(v json.RawMessage) string {
+	if v == nil {
+		return "<missing>"
+	}
+	return string(v)
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+type Backoff interface {
+	// Next 返回第attempt次调用失败后重试前的等待时间，attempt从1开始
+	Next(attempt int) time.Duration
+}
+
+type BackoffFunc func(attempt int) time.Duration
+
+func (f BackoffFunc) Next(attempt int) time.Duration {
+	return f(attempt)
+}
+
+// ConstantBackoff 固定等待时间
+func ConstantBackoff(d time.Duration) Backoff {
+	return BackoffFunc(func(attempt int) time.Duration {
+		return d
+	})
+}
+
+// ExponentialBackoff 指数增长的等待时间：initial * multiplier^(attempt-1)，不超过max（max<=0时不限制）
+func ExponentialBackoff(initial, max time.Duration, multiplier float64) Backoff {
+	return BackoffFunc(func(attempt int) time.Duration {
+		d := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+		if max > 0 && d > float64(max) {
+			return max
+		}
+		return time.Duration(d)
+	})
+}
+
+// JitterBackoff 在backoff的基础上增加随机抖动，实际等待时间在[d*(1-factor), d]之间
+// factor： 抖动比例，取值范围[0, 1]，为1时即full jitter
+func JitterBackoff(backoff Backoff, factor float64) Backoff {
+	if factor < 0 {
+		factor = 0
+	} else if factor > 1 {
+		factor = 1
+	}
+	return BackoffFunc(func(attempt int) time.Duration {
+		d := float64(backoff.Next(attempt))
+		return time.Duration(d * (1 - factor*rand.Float64()))
+	})
+}
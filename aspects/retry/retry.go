@@ -0,0 +1,144 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package retry
+
+import (
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"time"
+)
+
+// Classifier 判断错误是否需要重试
+type Classifier func(err error) bool
+
+// Attempt 一次失败调用的信息
+type Attempt struct {
+	Method string
+	// Attempt 调用次数，从1开始
+	Attempt int
+	Err     error
+	// Delay 重试前的等待时间，不再重试时为0
+	Delay time.Duration
+	// Retry 是否继续重试
+	Retry bool
+}
+
+type retrier struct {
+	maxAttempts int
+	maxElapsed  time.Duration
+	backoff     Backoff
+	classifier  Classifier
+	onAttempt   func(a Attempt)
+}
+
+type Opt func(r *retrier)
+
+// New 创建重试通知，方法最后一个error返回值满足Classifier时重新调用；
+// 方法声明了context.Context参数时，context结束后不再重试
+func New(opts ...Opt) aop.Advice {
+	r := &retrier{
+		maxAttempts: 3,
+		backoff:     ExponentialBackoff(100*time.Millisecond, 5*time.Second, 2),
+		classifier: func(err error) bool {
+			return err != nil
+		},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r.advice
+}
+
+// OptSetMaxAttempts 设置最大调用次数（包含首次调用），默认为3
+func OptSetMaxAttempts(n int) Opt {
+	return func(r *retrier) {
+		r.maxAttempts = n
+	}
+}
+
+// OptSetMaxElapsed 设置从首次调用开始的最长重试时间，为0时不限制
+func OptSetMaxElapsed(d time.Duration) Opt {
+	return func(r *retrier) {
+		r.maxElapsed = d
+	}
+}
+
+// OptSetBackoff 设置退避策略，默认为100ms起始、最大5s的指数退避
+func OptSetBackoff(backoff Backoff) Opt {
+	return func(r *retrier) {
+		r.backoff = backoff
+	}
+}
+
+// OptSetClassifier 设置需要重试的错误，默认所有错误均重试
+func OptSetClassifier(classifier Classifier) Opt {
+	return func(r *retrier) {
+		r.classifier = classifier
+	}
+}
+
+// OptSetOnAttempt 设置每次调用失败后的回调，可用于记录日志
+func OptSetOnAttempt(hook func(a Attempt)) Opt {
+	return func(r *retrier) {
+		r.onAttempt = hook
+	}
+}
+
+func (r *retrier) advice(invocation aop.Invocation, params []interface{}) []interface{} {
+	method := aop.JoinPointOf(invocation).Method()
+	mt := method.Type
+	if !methodfunc.ReturnsError(mt) {
+		return invocation.Invoke(params)
+	}
+	ctx, _ := methodfunc.MethodContext(method, params)
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		ret := invocation.Invoke(params)
+		err := methodfunc.TrailingError(mt, ret)
+		if err == nil || !r.classifier(err) {
+			return ret
+		}
+
+		a := Attempt{
+			Method:  invocation.MethodName(),
+			Attempt: attempt,
+			Err:     err,
+		}
+		if attempt < r.maxAttempts && ctx.Err() == nil {
+			a.Delay = r.backoff.Next(attempt)
+			a.Retry = r.maxElapsed <= 0 || time.Since(start)+a.Delay <= r.maxElapsed
+		}
+		if !a.Retry {
+			a.Delay = 0
+		}
+		if r.onAttempt != nil {
+			r.onAttempt(a)
+		}
+		if !a.Retry {
+			return ret
+		}
+
+		timer := time.NewTimer(a.Delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ret
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package shadow
+
+import (
+	"context"
+	"github.com/xfali/aop"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Diff 主实现与候选实现结果不一致的调用
+type Diff struct {
+	Type   string
+	Method string
+	Args   []interface{}
+	// Primary 主实现的返回值
+	Primary []interface{}
+	// Candidate 候选实现的返回值，调用失败时为nil
+	Candidate []interface{}
+	// CandidateErr 候选实现调用失败（方法不存在、参数不匹配或panic）时的错误
+	CandidateErr error
+	// CandidateElapsed 候选实现的耗时
+	CandidateElapsed time.Duration
+}
+
+type DiffReporter interface {
+	// Report 报告不一致的调用，在候选实现的调用协程中执行
+	Report(d Diff)
+}
+
+// DiffReporterFunc 函数形式的DiffReporter
+type DiffReporterFunc func(d Diff)
+
+func (f DiffReporterFunc) Report(d Diff) {
+	f(d)
+}
+
+// Comparator 比较主实现与候选实现的返回值，一致时返回true
+type Comparator func(method string, primary, candidate []interface{}) bool
+
+// DefaultComparator 使用reflect.DeepEqual比较返回值，error按Error()比较
+func DefaultComparator(method string, primary, candidate []interface{}) bool {
+	if len(primary) != len(candidate) {
+		return false
+	}
+	for i := range primary {
+		pe, pok := primary[i].(error)
+		ce, cok := candidate[i].(error)
+		if pok || cok {
+			if !pok || !cok || pe.Error() != ce.Error() {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(primary[i], candidate[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats 影子调用统计
+type Stats struct {
+	// Calls 已完成比较的调用数
+	Calls uint64
+	// Mismatches 结果不一致的调用数
+	Mismatches uint64
+	// Dropped 因并发数达到上限而未执行的影子调用数
+	Dropped uint64
+}
+
+type Shadow struct {
+	candidate  aop.Proxy
+	reporter   DiffReporter
+	comparator Comparator
+	sem        chan struct{}
+	wg         sync.WaitGroup
+
+	calls      uint64
+	mismatches uint64
+	dropped    uint64
+}
+
+type Opt func(s *Shadow)
+
+// New 创建影子流量通知
+// candidate： 候选（新）实现，需包含与主实现同名的方法
+// reporter： 不一致结果的报告方式
+func New(candidate interface{}, reporter DiffReporter, opts ...Opt) *Shadow {
+	s := &Shadow{
+		candidate:  aop.New(candidate, aop.OptSetRecoverPolicy(aop.RecoverError)),
+		reporter:   reporter,
+		comparator: DefaultComparator,
+		sem:        make(chan struct{}, 64),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// OptSetComparator 设置结果比较方式，默认为DefaultComparator
+func OptSetComparator(comparator Comparator) Opt {
+	return func(s *Shadow) {
+		s.comparator = comparator
+	}
+}
+
+// OptSetMaxConcurrent 设置同时执行的影子调用数上限，超出时丢弃影子调用，默认为64
+func OptSetMaxConcurrent(n int) Opt {
+	return func(s *Shadow) {
+		if n > 0 {
+			s.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+// Proxy 返回同时代理主实现及候选实现的Proxy，所有方法均产生影子调用
+// primary： 主（旧）实现，返回值以其为准
+func (s *Shadow) Proxy(primary interface{}, opts ...aop.Opt) aop.Proxy {
+	p := aop.New(primary, opts...)
+	p.AddAdvisor(aop.PointCutRegExp("", "", nil, nil), s.Advice())
+	return p
+}
+
+// Advice 返回影子流量通知：调用主实现并返回其结果，之后异步以相同参数调用候选实现的同名方法并比较结果；
+// 主实现panic时不产生影子调用。context.Context参数以context.WithoutCancel传递给候选实现，
+// 注意参数为指针等引用类型时两个实现共享同一对象
+func (s *Shadow) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		ret := invocation.Invoke(params)
+		select {
+		case s.sem <- struct{}{}:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+			return ret
+		}
+		// 复制参数及结果，外层通知可能原地修改返回的结果
+		ps := append([]interface{}(nil), params...)
+		primary := append([]interface{}(nil), ret...)
+		s.wg.Add(1)
+		go s.shadow(invocation, ps, primary)
+		return ret
+	}
+}
+
+// Wait 等待执行中的影子调用结束
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+// Stats 返回影子调用统计
+func (s *Shadow) Stats() Stats {
+	return Stats{
+		Calls:      atomic.LoadUint64(&s.calls),
+		Mismatches: atomic.LoadUint64(&s.mismatches),
+		Dropped:    atomic.LoadUint64(&s.dropped),
+	}
+}
+
+func (s *Shadow) shadow(invocation aop.Invocation, params []interface{}, primary []interface{}) {
+	defer func() {
+		<-s.sem
+		s.wg.Done()
+	}()
+	ps := make([]interface{}, len(params))
+	for i, p := range params {
+		if ctx, ok := p.(context.Context); ok && ctx != nil {
+			p = context.WithoutCancel(ctx)
+		}
+		ps[i] = p
+	}
+	now := time.Now()
+	candidate, err := s.candidate.Call(invocation.MethodName(), ps...)
+	elapsed := time.Since(now)
+	atomic.AddUint64(&s.calls, 1)
+	if err == nil && s.comparator(invocation.MethodName(), primary, candidate) {
+		return
+	}
+	atomic.AddUint64(&s.mismatches, 1)
+	s.reporter.Report(Diff{
+		Type:             aop.JoinPointOf(invocation).TargetType().String(),
+		Method:           invocation.MethodName(),
+		Args:             params,
+		Primary:          primary,
+		Candidate:        candidate,
+		CandidateErr:     err,
+		CandidateElapsed: elapsed,
+	})
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package singleflight
+
+import (
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/cache"
+	"sync"
+)
+
+type call struct {
+	wg    sync.WaitGroup
+	ret   []interface{}
+	panic interface{}
+	// waiters 等待该调用结果的合并调用数，由Group.lock保护
+	waiters int
+}
+
+type Group struct {
+	keyFunc cache.KeyFunc
+
+	lock  sync.Mutex
+	calls map[string]*call
+}
+
+type Opt func(g *Group)
+
+// New 创建调用合并组
+func New(opts ...Opt) *Group {
+	g := &Group{
+		keyFunc: cache.HashKey,
+		calls:   make(map[string]*call),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// OptSetKeyFunc 设置参数key生成方式，默认为cache.HashKey（不包含context.Context参数）
+func OptSetKeyFunc(keyFunc cache.KeyFunc) Opt {
+	return func(g *Group) {
+		g.keyFunc = keyFunc
+	}
+}
+
+// Advice 返回合并调用的通知：同一类型、方法及参数key的并发调用只执行一次，
+// 所有等待者获得相同的返回值（包括最后一个error），执行中panic时所有等待者以相同的值panic。
+// 注意等待者共享首个调用者的context，适用于只读且开销较大的方法
+func (g *Group) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		key := aop.JoinPointOf(invocation).TargetType().String() + "." + invocation.MethodName() + ":" + g.keyFunc(params)
+
+		g.lock.Lock()
+		if c, ok := g.calls[key]; ok {
+			c.waiters++
+			g.lock.Unlock()
+			c.wg.Wait()
+			if c.panic != nil {
+				panic(c.panic)
+			}
+			return append([]interface{}(nil), c.ret...)
+		}
+		c := &call{}
+		c.wg.Add(1)
+		g.calls[key] = c
+		g.lock.Unlock()
+
+		g.do(key, c, invocation, params)
+		if c.panic != nil {
+			panic(c.panic)
+		}
+		return append([]interface{}(nil), c.ret...)
+	}
+}
+
+// InFlight 返回正在执行的合并调用数
+func (g *Group) InFlight() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return len(g.calls)
+}
+
+// Waiters 返回等待正在执行的调用结果的合并调用数（不包含执行调用者）
+func (g *Group) Waiters() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	n := 0
+	for _, c := range g.calls {
+		n += c.waiters
+	}
+	return n
+}
+
+func (g *Group) do(key string, c *call, invocation aop.Invocation, params []interface{}) {
+	defer func() {
+		if o := recover(); o != nil {
+			c.panic = o
+		}
+		g.lock.Lock()
+		delete(g.calls, key)
+		g.lock.Unlock()
+		c.wg.Done()
+	}()
+	c.ret = invocation.Invoke(params)
+}
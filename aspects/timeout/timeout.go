@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"sync"
+	"time"
+)
+
+// ErrTimeout 调用超时，可通过errors.Is(err, ErrTimeout)判断
+var ErrTimeout = errors.New("timeout: call timed out")
+
+type TimeoutError struct {
+	Method  string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout: method %s timed out after %s", e.Method, e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// Late 超时后仍在执行的调用最终完成时的结果
+type Late struct {
+	Method  string
+	Results []interface{}
+	// Panic 调用发生panic时的值
+	Panic interface{}
+	// Elapsed 调用实际耗时
+	Elapsed time.Duration
+}
+
+type timeout struct {
+	timeout time.Duration
+	onLate  func(l Late)
+}
+
+type Opt func(t *timeout)
+
+// New 创建超时通知：
+//
+// 方法参数包含context.Context时，以带有超时的子context替换该参数并同步调用，由目标方法响应context结束；
+//
+// 否则在新的goroutine中调用，超时后立即返回：方法最后一个返回值为error时返回*TimeoutError，
+// 否则以*TimeoutError panic。超时后目标方法无法被中断，该goroutine将继续执行直至目标方法返回，
+// 其结果（或panic）被丢弃，可通过OptSetOnLate观察。目标方法永不返回时该goroutine将泄漏，
+// 此类方法应改为接收context.Context。
+// d： 超时时间
+func New(d time.Duration, opts ...Opt) aop.Advice {
+	t := &timeout{
+		timeout: d,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t.advice
+}
+
+// OptSetOnLate 设置超时后仍在执行的调用最终完成时的回调，回调在调用所在的goroutine中执行
+func OptSetOnLate(hook func(l Late)) Opt {
+	return func(t *timeout) {
+		t.onLate = hook
+	}
+}
+
+func (t *timeout) advice(invocation aop.Invocation, params []interface{}) []interface{} {
+	method := aop.JoinPointOf(invocation).Method()
+	if index := methodfunc.ContextIndex(method); index >= 0 && index < len(params) {
+		parent, _ := params[index].(context.Context)
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(parent, t.timeout)
+		defer cancel()
+		ps := make([]interface{}, len(params))
+		copy(ps, params)
+		ps[index] = ctx
+		return invocation.Invoke(ps)
+	}
+	return t.invokeAsync(invocation, params)
+}
+
+type result struct {
+	ret      []interface{}
+	panicked bool
+	value    interface{}
+}
+
+func (t *timeout) invokeAsync(invocation aop.Invocation, params []interface{}) []interface{} {
+	// 缓冲为1，调用完成时无需等待接收方
+	ch := make(chan result, 1)
+	lock := sync.Mutex{}
+	abandoned := false
+	start := time.Now()
+	go func() {
+		r := result{panicked: true}
+		defer func() {
+			if r.panicked {
+				r.value = recover()
+			}
+			lock.Lock()
+			if !abandoned {
+				ch <- r
+				lock.Unlock()
+				return
+			}
+			lock.Unlock()
+			if t.onLate != nil {
+				t.onLate(Late{
+					Method:  invocation.MethodName(),
+					Results: r.ret,
+					Panic:   r.value,
+					Elapsed: time.Since(start),
+				})
+			}
+		}()
+		r.ret = invocation.Invoke(params)
+		r.panicked = false
+	}()
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	var r result
+	select {
+	case r = <-ch:
+	case <-timer.C:
+		lock.Lock()
+		select {
+		// 超时的同时调用已完成
+		case r = <-ch:
+		default:
+			abandoned = true
+		}
+		lock.Unlock()
+	}
+	if !abandoned {
+		if r.panicked {
+			panic(r.value)
+		}
+		return r.ret
+	}
+
+	err := &TimeoutError{Method: invocation.MethodName(), Timeout: t.timeout}
+	return methodfunc.ErrorResults(aop.JoinPointOf(invocation).Method().Type, err)
+}
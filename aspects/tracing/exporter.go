@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// InMemoryExporter 将span保存在内存中，用于测试
+type InMemoryExporter struct {
+	lock  sync.Mutex
+	spans []*SpanData
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) Export(span *SpanData) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = append(e.spans, span)
+	return nil
+}
+
+// Spans 返回已导出的span，按结束顺序排列
+func (e *InMemoryExporter) Spans() []*SpanData {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]*SpanData(nil), e.spans...)
+}
+
+// Reset 清除已导出的span
+func (e *InMemoryExporter) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = nil
+}
+
+type jsonExporter struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewJSONExporter 以JSON lines格式导出span
+func NewJSONExporter(w io.Writer) *jsonExporter {
+	return &jsonExporter{encoder: json.NewEncoder(w)}
+}
+
+// NewJSONFileExporter 以JSON lines格式将span追加写入文件，用于本地调试
+func NewJSONFileExporter(path string) (*jsonExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonExporter{
+		encoder: json.NewEncoder(f),
+		closer:  f,
+	}, nil
+}
+
+func (e *jsonExporter) Export(span *SpanData) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.encoder.Encode(span)
+}
+
+// Close 关闭导出文件
+func (e *jsonExporter) Close() error {
+	if e.closer != nil {
+		return e.closer.Close()
+	}
+	return nil
+}
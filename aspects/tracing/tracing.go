@@ -0,0 +1,259 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+func (c StatusCode) String() string {
+	switch c {
+	case StatusOK:
+		return "OK"
+	case StatusError:
+		return "ERROR"
+	}
+	return "UNSET"
+}
+
+func (c StatusCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *StatusCode) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "OK":
+		*c = StatusOK
+	case "ERROR":
+		*c = StatusError
+	default:
+		*c = StatusUnset
+	}
+	return nil
+}
+
+// SpanData 已结束的span数据，由Exporter导出
+type SpanData struct {
+	Name         string                 `json:"name"`
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	StartTime    time.Time              `json:"start_time"`
+	EndTime      time.Time              `json:"end_time"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Status       StatusCode             `json:"status"`
+	StatusMsg    string                 `json:"status_message,omitempty"`
+}
+
+type Exporter interface {
+	// Export 导出已结束的span
+	Export(span *SpanData) error
+}
+
+type Span struct {
+	tracer *Tracer
+	lock   sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// TraceID 返回trace id
+func (s *Span) TraceID() string {
+	return s.data.TraceID
+}
+
+// SpanID 返回span id
+func (s *Span) SpanID() string {
+	return s.data.SpanID
+}
+
+// SetAttribute 设置span属性
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// RecordError 记录错误并将状态设置为StatusError
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Status = StatusError
+	s.data.StatusMsg = err.Error()
+}
+
+// SetStatus 设置span状态
+func (s *Span) SetStatus(code StatusCode, msg string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Status = code
+	s.data.StatusMsg = msg
+}
+
+// End 结束span并导出，重复调用无效
+func (s *Span) End() {
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.lock.Unlock()
+	s.tracer.exporter.Export(&data)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan 返回携带span的context
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext 返回context中的span，不存在时返回nil
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+type Tracer struct {
+	exporter   Exporter
+	recordArgs bool
+}
+
+type Opt func(t *Tracer)
+
+// NewTracer 创建Tracer
+// exporter： span导出器
+func NewTracer(exporter Exporter, opts ...Opt) *Tracer {
+	t := &Tracer{
+		exporter:   exporter,
+		recordArgs: true,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// OptSetRecordArgs 设置是否将方法参数记录为span属性，默认记录
+func OptSetRecordArgs(record bool) Opt {
+	return func(t *Tracer) {
+		t.recordArgs = record
+	}
+}
+
+// Start 创建span，ctx中存在span时作为其子span
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:      name,
+			SpanID:    newID(8),
+			StartTime: time.Now(),
+		},
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.data.TraceID = parent.data.TraceID
+		s.data.ParentSpanID = parent.data.SpanID
+	} else {
+		s.data.TraceID = newID(16)
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// Advice 返回为每次调用创建span的通知，span名称为type.method；
+// 方法声明了context.Context参数时，从中获取父span并将携带当前span的context传递给目标方法；
+// 参数为实现了context.Context的具体类型时只从中获取父span
+func (t *Tracer) Advice() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+		jp := aop.JoinPointOf(invocation)
+		index := methodfunc.ContextIndex(jp.Method())
+		var ctx context.Context
+		if index >= 0 && index < len(params) {
+			ctx, _ = params[index].(context.Context)
+		}
+		if ctx == nil {
+			ctx, _ = methodfunc.FindContext(params)
+		}
+		ctx, span := t.Start(ctx, jp.TargetType().String()+"."+invocation.MethodName())
+		defer span.End()
+		if index >= 0 && index < len(params) {
+			ps := make([]interface{}, len(params))
+			copy(ps, params)
+			ps[index] = ctx
+			params = ps
+		}
+		if t.recordArgs {
+			for i, p := range params {
+				if _, ok := p.(context.Context); !ok {
+					span.SetAttribute("arg."+strconv.Itoa(i), fmt.Sprintf("%v", p))
+				}
+			}
+		}
+		defer func() {
+			if o := recover(); o != nil {
+				span.SetStatus(StatusError, fmt.Sprintf("panic: %v", o))
+				span.End()
+				panic(o)
+			}
+		}()
+		ret = invocation.Invoke(params)
+		if err := methodfunc.TrailingError(jp.Method().Type, ret); err != nil {
+			span.RecordError(err)
+		} else {
+			span.SetStatus(StatusOK, "")
+		}
+		return ret
+	}
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
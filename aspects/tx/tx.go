@@ -0,0 +1,208 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+)
+
+var (
+	// ErrNoContext 方法参数不包含context.Context，无法传递事务
+	ErrNoContext = errors.New("tx: method must accept context.Context")
+	// ErrRollbackOnly 加入的事务中有调用失败，事务已回滚
+	ErrRollbackOnly = errors.New("tx: transaction marked as rollback-only")
+)
+
+type Propagation int
+
+const (
+	// Required 存在事务时加入，否则开启新事务
+	Required Propagation = iota
+	// RequiresNew 总是开启新事务，已存在的事务在新事务结束前挂起
+	RequiresNew
+	// Nested 存在事务时在其中创建保存点，失败时回滚至保存点，否则同Required
+	Nested
+	// Supports 存在事务时加入，否则以非事务方式执行
+	Supports
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case RequiresNew:
+		return "REQUIRES_NEW"
+	case Nested:
+		return "NESTED"
+	case Supports:
+		return "SUPPORTS"
+	}
+	return "REQUIRED"
+}
+
+// Definition 事务定义
+type Definition struct {
+	Propagation Propagation
+	Isolation   sql.IsolationLevel
+	ReadOnly    bool
+}
+
+// Executor *sql.DB及*sql.Tx的公共方法
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txState struct {
+	db           *sql.DB
+	tx           *sql.Tx
+	savepoints   int
+	rollbackOnly bool
+}
+
+type txKey struct{}
+
+// FromContext 返回context中的事务
+func FromContext(ctx context.Context) (*sql.Tx, bool) {
+	s, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	return s.tx, true
+}
+
+type Manager struct {
+	db *sql.DB
+}
+
+// New 创建事务管理器
+func New(db *sql.DB) *Manager {
+	return &Manager{db: db}
+}
+
+// Executor 返回context中属于该管理器的事务，不存在时返回*sql.DB，供Repository使用
+func (m *Manager) Executor(ctx context.Context) Executor {
+	if s, ok := ctx.Value(txKey{}).(*txState); ok && s.db == m.db {
+		return s.tx
+	}
+	return m.db
+}
+
+// Advice 返回声明式事务通知，目标方法需接收context.Context，事务通过该context传递：
+// 方法最后一个error返回值不为nil或发生panic时回滚，否则提交
+func (m *Manager) Advice(def Definition) aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+		method := aop.JoinPointOf(invocation).Method()
+		mt := method.Type
+		index := methodfunc.ContextIndex(method)
+		if index < 0 || index >= len(params) {
+			return methodfunc.ErrorResults(mt, ErrNoContext)
+		}
+		ctx, _ := params[index].(context.Context)
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		current, _ := ctx.Value(txKey{}).(*txState)
+		if current != nil && current.db != m.db {
+			current = nil
+		}
+
+		switch {
+		case def.Propagation == Supports && current == nil:
+			return invocation.Invoke(params)
+		case (def.Propagation == Required || def.Propagation == Supports) && current != nil:
+			return m.join(current, invocation, params)
+		case def.Propagation == Nested && current != nil:
+			return m.nested(ctx, current, invocation, params)
+		}
+
+		tx, err := m.db.BeginTx(ctx, &sql.TxOptions{Isolation: def.Isolation, ReadOnly: def.ReadOnly})
+		if err != nil {
+			return methodfunc.ErrorResults(mt, err)
+		}
+		state := &txState{db: m.db, tx: tx}
+		done := false
+		defer func() {
+			if !done {
+				tx.Rollback()
+			}
+		}()
+		ret = invocation.Invoke(withContext(params, index, context.WithValue(ctx, txKey{}, state)))
+		done = true
+		if err := methodfunc.TrailingError(mt, ret); err != nil {
+			tx.Rollback()
+			return ret
+		}
+		if state.rollbackOnly {
+			tx.Rollback()
+			return methodfunc.ErrorResults(mt, ErrRollbackOnly)
+		}
+		if err := tx.Commit(); err != nil {
+			return methodfunc.ErrorResults(mt, err)
+		}
+		return ret
+	}
+}
+
+func (m *Manager) join(state *txState, invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+	failed := true
+	defer func() {
+		if failed {
+			state.rollbackOnly = true
+		}
+	}()
+	ret = invocation.Invoke(params)
+	failed = methodfunc.TrailingError(aop.JoinPointOf(invocation).Method().Type, ret) != nil
+	return ret
+}
+
+func (m *Manager) nested(ctx context.Context, state *txState, invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+	mt := aop.JoinPointOf(invocation).Method().Type
+	state.savepoints++
+	sp := fmt.Sprintf("sp_%d", state.savepoints)
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT "+sp); err != nil {
+		return methodfunc.ErrorResults(mt, err)
+	}
+	done := false
+	defer func() {
+		if !done {
+			state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp)
+		}
+	}()
+	ret = invocation.Invoke(params)
+	done = true
+	if methodfunc.TrailingError(mt, ret) != nil {
+		state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp)
+		return ret
+	}
+	if _, err := state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp); err != nil {
+		return methodfunc.ErrorResults(mt, err)
+	}
+	return ret
+}
+
+func withContext(params []interface{}, index int, ctx context.Context) []interface{} {
+	ps := make([]interface{}, len(params))
+	copy(ps, params)
+	ps[index] = ctx
+	return ps
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package txtest 提供记录语句的database/sql/driver实现，用于测试事务通知
+package txtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const DriverName = "aop-txtest"
+
+var (
+	registerOnce sync.Once
+	recorders    sync.Map
+	dsnSeq       int64
+)
+
+// Recorder 记录连接上执行的事务操作及语句
+type Recorder struct {
+	lock   sync.Mutex
+	events []string
+	connID int64
+}
+
+// Open 打开记录语句的*sql.DB，每次调用使用独立的Recorder
+func Open() (*sql.DB, *Recorder) {
+	registerOnce.Do(func() {
+		sql.Register(DriverName, fakeDriver{})
+	})
+	r := &Recorder{}
+	dsn := strconv.FormatInt(atomic.AddInt64(&dsnSeq, 1), 10)
+	recorders.Store(dsn, r)
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		panic(err)
+	}
+	return db, r
+}
+
+// Events 返回已记录的事件，格式为"连接序号 语句"，如"1 BEGIN"、"1 INSERT ..."、"1 COMMIT"
+func (r *Recorder) Events() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// Reset 清除已记录的事件
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = nil
+}
+
+func (r *Recorder) record(conn int64, stmt string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, fmt.Sprintf("%d %s", conn, stmt))
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	v, ok := recorders.Load(name)
+	if !ok {
+		return nil, errors.New("txtest: unknown dsn " + name)
+	}
+	r := v.(*Recorder)
+	return &conn{id: atomic.AddInt64(&r.connID, 1), recorder: r}, nil
+}
+
+type conn struct {
+	id       int64
+	recorder *Recorder
+	inTx     bool
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.inTx {
+		return nil, errors.New("txtest: transaction already begun")
+	}
+	c.inTx = true
+	c.recorder.record(c.id, "BEGIN")
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.recorder.record(c.id, query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.recorder.record(c.id, query)
+	return &rows{}, nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	t.conn.inTx = false
+	t.conn.recorder.record(t.conn.id, "COMMIT")
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.conn.inTx = false
+	t.conn.recorder.record(t.conn.id, "ROLLBACK")
+	return nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.recorder.record(s.conn.id, s.query)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.recorder.record(s.conn.id, s.query)
+	return &rows{}, nil
+}
+
+type rows struct{}
+
+func (r *rows) Columns() []string {
+	return nil
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	return io.EOF
+}
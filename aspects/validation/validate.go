@@ -0,0 +1,356 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TagName 校验规则的结构体标签，如`validate:"required,min=1,email"`
+const TagName = "validate"
+
+var (
+	// ErrValidation 参数校验失败，可通过errors.Is(err, ErrValidation)判断
+	ErrValidation = errors.New("validation: invalid params")
+	// ErrInvalidRule 结构体标签中的校验规则有误，可通过errors.Is(err, ErrInvalidRule)判断
+	ErrInvalidRule = errors.New("validation: invalid rule")
+)
+
+// RuleError 结构体标签中的校验规则有误（未知规则或参数错误）
+type RuleError struct {
+	Type   reflect.Type
+	Field  string
+	Rule   string
+	Reason string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("validation: invalid rule %q on %s.%s: %s", e.Rule, e.Type.String(), e.Field, e.Reason)
+}
+
+func (e *RuleError) Is(target error) bool {
+	return target == ErrInvalidRule
+}
+
+// FieldError 字段校验失败
+type FieldError struct {
+	// Field 字段路径，如CreateUserReq.Address.City
+	Field string
+	Rule  string
+	Param string
+	Value interface{}
+}
+
+func (e *FieldError) Error() string {
+	switch e.Rule {
+	case "required":
+		return fmt.Sprintf("%s is required", e.Field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email", e.Field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", e.Field, e.Param)
+	}
+	return fmt.Sprintf("%s failed on rule %s=%s", e.Field, e.Rule, e.Param)
+}
+
+// ValidationError 汇总全部字段校验错误
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "validation: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+type rule struct {
+	name  string
+	param string
+	// n min、max、len的比较值
+	n float64
+	// options oneof的可选值
+	options []string
+}
+
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+type typeFields struct {
+	fields []field
+	err    error
+}
+
+var (
+	fieldCache sync.Map
+	emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+)
+
+// Validate 按结构体标签校验v（结构体或其指针），嵌套的结构体及结构体切片同样校验，
+// 校验失败返回*ValidationError，标签中的规则有误时返回*RuleError，v不是结构体时返回nil
+// 支持的规则：required、min=N、max=N、len=N（数值比较值，字符串、切片、map比较长度）、email、oneof=a b c
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs []*FieldError
+	if err := validateStruct(rv, rv.Type().Name(), &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// CheckRules 检查v（结构体、结构体指针或reflect.Type）及其嵌套结构体标签中的规则，
+// 规则有误时返回*RuleError，用于在启动时发现标签错误
+func CheckRules(v interface{}) error {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	return checkType(t, map[reflect.Type]bool{})
+}
+
+func checkType(t reflect.Type, visited map[reflect.Type]bool) error {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || visited[t] {
+		return nil
+	}
+	visited[t] = true
+	fields, err := fieldsOf(t)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := checkType(t.Field(f.index).Type, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, path string, errs *[]*FieldError) error {
+	fields, err := fieldsOf(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		fpath := path + "." + f.name
+		valid := true
+		for _, r := range f.rules {
+			if !check(r, fv) {
+				*errs = append(*errs, &FieldError{
+					Field: fpath,
+					Rule:  r.name,
+					Param: r.param,
+					Value: fv.Interface(),
+				})
+				valid = false
+				break
+			}
+		}
+		if valid {
+			if err := validateNested(fv, fpath, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateNested(v reflect.Value, path string, errs *[]*FieldError) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return validateStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateNested(v.Index(i), path+"["+strconv.Itoa(i)+"]", errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldsOf 返回结构体的字段及已解析的规则，结果（包括规则错误）按类型缓存
+func fieldsOf(t reflect.Type) ([]field, error) {
+	if v, ok := fieldCache.Load(t); ok {
+		tf := v.(*typeFields)
+		return tf.fields, tf.err
+	}
+	tf := &typeFields{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		rules, err := parseRules(f.Tag.Get(TagName))
+		if err != nil {
+			err.Type = t
+			err.Field = f.Name
+			tf.fields, tf.err = nil, err
+			break
+		}
+		tf.fields = append(tf.fields, field{
+			index: i,
+			name:  f.Name,
+			rules: rules,
+		})
+	}
+	fieldCache.Store(t, tf)
+	return tf.fields, tf.err
+}
+
+func parseRules(tag string) ([]rule, *RuleError) {
+	if tag == "" || tag == "-" {
+		return nil, nil
+	}
+	var rules []rule
+	for _, s := range strings.Split(tag, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		r := rule{name: s}
+		if i := strings.Index(s, "="); i >= 0 {
+			r.name, r.param = s[:i], s[i+1:]
+		}
+		switch r.name {
+		case "required", "email":
+			if r.param != "" {
+				return nil, &RuleError{Rule: s, Reason: "rule takes no param"}
+			}
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(r.param, 64)
+			if err != nil {
+				return nil, &RuleError{Rule: s, Reason: "param must be a number"}
+			}
+			r.n = n
+		case "oneof":
+			r.options = strings.Fields(r.param)
+			if len(r.options) == 0 {
+				return nil, &RuleError{Rule: s, Reason: "param must list at least one value"}
+			}
+		default:
+			return nil, &RuleError{Rule: s, Reason: "unknown rule"}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func check(r rule, v reflect.Value) bool {
+	switch r.name {
+	case "required":
+		return !v.IsZero()
+	case "min", "max", "len":
+		if isEmptyOptional(v) {
+			return true
+		}
+		n, ok := measure(v)
+		if !ok {
+			return true
+		}
+		switch r.name {
+		case "min":
+			return n >= r.n
+		case "max":
+			return n <= r.n
+		}
+		return n == r.n
+	case "email":
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return true
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.String || v.String() == "" {
+			return true
+		}
+		return emailRegex.MatchString(v.String())
+	case "oneof":
+		if isEmptyOptional(v) {
+			return true
+		}
+		s := fmt.Sprintf("%v", reflect.Indirect(v).Interface())
+		for _, o := range r.options {
+			if o == s {
+				return true
+			}
+		}
+		return false
+	}
+	// 规则已在fieldsOf中校验
+	return true
+}
+
+// isEmptyOptional nil指针不参与min、max、len、oneof校验，需配合required使用
+func isEmptyOptional(v reflect.Value) bool {
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+func measure(v reflect.Value) (float64, bool) {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package validation
+
+import (
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/methodfunc"
+)
+
+// New 创建参数校验通知，依次校验全部参数，任一失败时不调用目标方法：
+// 方法最后一个返回值为error时返回汇总全部参数错误的*ValidationError，否则以*ValidationError panic；
+// 参数类型的标签规则有误时同样处理，错误为*RuleError。可在启动时使用CheckRules提前检查标签
+func New() aop.Advice {
+	return func(invocation aop.Invocation, params []interface{}) []interface{} {
+		var errs []*FieldError
+		var err error
+		for _, p := range params {
+			var ve *ValidationError
+			if verr := Validate(p); errors.As(verr, &ve) {
+				errs = append(errs, ve.Errors...)
+			} else if verr != nil {
+				err = verr
+				break
+			}
+		}
+		if err == nil && len(errs) == 0 {
+			return invocation.Invoke(params)
+		}
+		if err == nil {
+			err = &ValidationError{Errors: errs}
+		}
+		return methodfunc.ErrorResults(aop.JoinPointOf(invocation).Method().Type, err)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 )
 
 type advisor struct {
@@ -34,6 +35,8 @@ type adviceData struct {
 }
 
 type chainProxy struct {
+	config
+	version  uint32
 	t        reflect.Type
 	value    reflect.Value
 	advisors []advisor
@@ -45,13 +48,14 @@ type chainProxy struct {
 	adviceDatas  map[string]*adviceData
 }
 
-func New(obj interface{}) *chainProxy {
+func New(obj interface{}, opts ...Opt) *chainProxy {
 	ret := &chainProxy{
 		t:           reflect.TypeOf(obj),
 		value:       reflect.ValueOf(obj),
 		methodIndex: make(map[string]reflect.Method),
 		adviceDatas: make(map[string]*adviceData),
 	}
+	ret.apply(opts)
 	return ret
 }
 
@@ -60,21 +64,59 @@ func (aop *chainProxy) AddAdvisor(pointCut PointCut, advice Advice) Proxy {
 		pointCut: pointCut,
 		advice:   advice,
 	})
+
+	// 通知链已改变，清除缓存
+	aop.adviceLocker.Lock()
+	aop.adviceDatas = make(map[string]*adviceData)
+	atomic.AddUint32(&aop.version, 1)
+	aop.adviceLocker.Unlock()
 	return aop
 }
 
+func (aop *chainProxy) target() reflect.Value {
+	return aop.value
+}
+
+func (aop *chainProxy) Method(method string) (MethodHandle, error) {
+	mt, _, err := aop.findMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	return newMethodHandle(mt, aop.value.Method(mt.Index), &aop.config, &aop.version, aop.findAdvisor), nil
+}
+
 func (aop *chainProxy) Call(method string, params ...interface{}) (ret []interface{}, err error) {
 	mt, _, err := aop.findMethod(method)
 	if err != nil {
 		return nil, err
 	}
+	fn := aop.value.Method(mt.Index)
+	in, err := paramValues(mt.Name, fn.Type(), params)
+	if err != nil {
+		return nil, err
+	}
+	defer aop.recoverCall(mt.Name, fn.Type(), &ret, &err)
 	advice, invocation := aop.findAdvisor(mt, params...)
 	if advice == nil {
-		return call(aop.value.Method(mt.Index), params...)
+		return callValue(fn, in), nil
 	}
 	return advice(invocation, params), nil
 }
 
+func (aop *chainProxy) CallE(method string, params ...interface{}) (ret []interface{}, err error) {
+	mt, _, err := aop.findMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	ret, err = aop.Call(method, params...)
+	return liftError(mt.Name, mt.Type, ret, err)
+}
+
+func (aop *chainProxy) CallInto(method string, dests []interface{}, params ...interface{}) error {
+	ret, err := aop.CallE(method, params...)
+	return callInto(method, dests, ret, err)
+}
+
 func (aop *chainProxy) findAdvisor(method reflect.Method, params ...interface{}) (Advice, Invocation) {
 	aop.adviceLocker.Lock()
 	defer aop.adviceLocker.Unlock()
@@ -84,7 +126,7 @@ func (aop *chainProxy) findAdvisor(method reflect.Method, params ...interface{})
 		return d.advice, d.invocation
 	}
 	var advice Advice
-	var invocation Invocation = newInvocation(method.Name, aop.value.Method(method.Index))
+	var invocation Invocation = newInvocation(method, aop.t, aop.value.Method(method.Index))
 	last := invocation
 	for i := len(aop.advisors) - 1; i >= 0; i-- {
 		v := aop.advisors[i]
@@ -117,7 +159,7 @@ func (aop *chainProxy) findMethod(method string) (reflect.Method, bool, error) {
 
 	mt, ok := aop.value.Type().MethodByName(method)
 	if !ok {
-		return reflect.Method{}, false, fmt.Errorf("Cannot found type %s with method %s ", aop.value.Type().String(), method)
+		return reflect.Method{}, false, &MethodNotFoundError{Type: aop.value.Type(), Method: method}
 	}
 	aop.methodIndex[method] = mt
 	return mt, false, nil
@@ -128,7 +170,7 @@ func (aop *chainProxy) invokeDefault(method string, params ...interface{}) ([]in
 	if err != nil {
 		return nil, err
 	}
-	return call(aop.value.Method(v.Index), params...)
+	return call(v.Name, aop.value.Method(v.Index), params...)
 }
 
 type chainInvocation struct {
@@ -144,6 +186,14 @@ func (i *chainInvocation) MethodName() string {
 	return i.invocation.MethodName()
 }
 
+func (i *chainInvocation) Method() reflect.Method {
+	return JoinPointOf(i.invocation).Method()
+}
+
+func (i *chainInvocation) TargetType() reflect.Type {
+	return JoinPointOf(i.invocation).TargetType()
+}
+
 func newChainInvocation(advice Advice, invocation Invocation) *chainInvocation {
 	return &chainInvocation{
 		advice:     advice,
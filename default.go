@@ -18,64 +18,103 @@
 package aop
 
 import (
-	"fmt"
 	"github.com/xfali/aop/methodfunc"
 	"reflect"
 	"sync"
+	"sync/atomic"
 )
 
 type meta struct {
-	advice     Advice
-	invocation Invocation
-	lock       sync.Mutex
+	advice      Advice
+	invocations map[string]Invocation
+	lock        sync.Mutex
 }
 
 type simpleProxy struct {
+	config
+	version     uint32
 	t           reflect.Type
 	value       reflect.Value
 	pointCuts   map[PointCut]*meta
 	methodIndex map[string]reflect.Method
 }
 
-func NewSimple(obj interface{}) *simpleProxy {
+func NewSimple(obj interface{}, opts ...Opt) *simpleProxy {
 	ret := &simpleProxy{
 		t:           reflect.TypeOf(obj),
 		value:       reflect.ValueOf(obj),
 		pointCuts:   make(map[PointCut]*meta),
 		methodIndex: make(map[string]reflect.Method),
 	}
+	ret.apply(opts)
 	return ret
 }
 
 func (aop *simpleProxy) AddAdvisor(pointCut PointCut, advice Advice) Proxy {
-	aop.pointCuts[pointCut] = &meta{advice: advice}
+	aop.pointCuts[pointCut] = &meta{
+		advice:      advice,
+		invocations: make(map[string]Invocation),
+	}
+	atomic.AddUint32(&aop.version, 1)
 	return aop
 }
 
+func (aop *simpleProxy) target() reflect.Value {
+	return aop.value
+}
+
+func (aop *simpleProxy) Method(method string) (MethodHandle, error) {
+	mt, err := aop.findMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	return newMethodHandle(mt, aop.value.Method(mt.Index), &aop.config, &aop.version, aop.findAdvisor), nil
+}
+
 func (aop *simpleProxy) Call(method string, params ...interface{}) (ret []interface{}, err error) {
 	mt, err := aop.findMethod(method)
 	if err != nil {
 		return nil, err
 	}
-	m, err := aop.findAdvisor(mt, params...)
+	fn := aop.value.Method(mt.Index)
+	in, err := paramValues(mt.Name, fn.Type(), params)
 	if err != nil {
 		return nil, err
 	}
-	if m == nil {
-		return call(aop.value.Method(mt.Index), params...)
+	defer aop.recoverCall(mt.Name, fn.Type(), &ret, &err)
+	advice, invocation := aop.findAdvisor(mt, params...)
+	if advice == nil {
+		return callValue(fn, in), nil
 	}
-	return m.advice(m.invocation, params), nil
+	return advice(invocation, params), nil
 }
 
-func (aop *simpleProxy) findAdvisor(method reflect.Method, params ...interface{}) (*meta, error) {
+func (aop *simpleProxy) CallE(method string, params ...interface{}) (ret []interface{}, err error) {
+	mt, err := aop.findMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	ret, err = aop.Call(method, params...)
+	return liftError(mt.Name, mt.Type, ret, err)
+}
+
+func (aop *simpleProxy) CallInto(method string, dests []interface{}, params ...interface{}) error {
+	ret, err := aop.CallE(method, params...)
+	return callInto(method, dests, ret, err)
+}
+
+func (aop *simpleProxy) findAdvisor(method reflect.Method, params ...interface{}) (Advice, Invocation) {
 	for k, v := range aop.pointCuts {
 		if k.Matches(method, aop.t, params...) {
+			// 切点可能匹配多个方法，按方法缓存调用
 			v.lock.Lock()
-			if v.invocation == nil {
-				v.invocation = newInvocation(method.Name, aop.value.Method(method.Index))
+			invocation, ok := v.invocations[method.Name]
+			if !ok {
+				invocation = newInvocation(method, aop.t, aop.value.Method(method.Index))
+				v.invocations[method.Name] = invocation
 			}
 			v.lock.Unlock()
-			return v, nil
+			return v.advice, invocation
 		}
 	}
 	return nil, nil
@@ -88,7 +127,7 @@ func (aop *simpleProxy) findMethod(method string) (reflect.Method, error) {
 
 	mt, ok := aop.value.Type().MethodByName(method)
 	if !ok {
-		return reflect.Method{}, fmt.Errorf("Cannot found type %s with method %s ", aop.value.Type().String(), method)
+		return reflect.Method{}, &MethodNotFoundError{Type: aop.value.Type(), Method: method}
 	}
 	aop.methodIndex[method] = mt
 	return mt, nil
@@ -99,42 +138,85 @@ func (aop *simpleProxy) invokeDefault(method string, params ...interface{}) ([]i
 	if err != nil {
 		return nil, err
 	}
-	return call(aop.value.Method(v.Index), params...)
+	return call(v.Name, aop.value.Method(v.Index), params...)
+}
+
+func call(name string, method reflect.Value, params ...interface{}) ([]interface{}, error) {
+	in, err := paramValues(name, method.Type(), params)
+	if err != nil {
+		return nil, err
+	}
+	return callValue(method, in), nil
 }
 
-func call(method reflect.Value, params ...interface{}) ([]interface{}, error) {
-	pn := method.Type().NumIn()
-	if pn != len(params) {
-		return nil, fmt.Errorf("Method expect param size: %d but get %d ", pn, len(params))
+// paramValues 校验参数数量及类型并转换为调用参数，避免reflect调用时panic
+func paramValues(name string, ft reflect.Type, params []interface{}) ([]reflect.Value, error) {
+	pn := ft.NumIn()
+	variadic := ft.IsVariadic()
+	if variadic {
+		if len(params) < pn-1 {
+			return nil, &ArityError{Method: name, Expect: pn - 1, Actual: len(params), Variadic: true}
+		}
+	} else if pn != len(params) {
+		return nil, &ArityError{Method: name, Expect: pn, Actual: len(params)}
 	}
-	var ret []reflect.Value
-	if len(params) > 0 {
-		pv := make([]reflect.Value, len(params))
-		for i, p := range params {
-			pv[i] = reflect.ValueOf(p)
+	if len(params) == 0 {
+		return nil, nil
+	}
+	pv := make([]reflect.Value, len(params))
+	for i, p := range params {
+		var pt reflect.Type
+		if variadic && i >= pn-1 {
+			pt = ft.In(pn - 1).Elem()
+		} else {
+			pt = ft.In(i)
+		}
+		if p == nil {
+			if !nillable(pt) {
+				return nil, &ArgumentTypeError{Method: name, Index: i, Expect: pt}
+			}
+			pv[i] = reflect.Zero(pt)
+			continue
+		}
+		v := reflect.ValueOf(p)
+		if !v.Type().AssignableTo(pt) {
+			return nil, &ArgumentTypeError{Method: name, Index: i, Expect: pt, Actual: v.Type()}
 		}
-		ret = method.Call(pv)
-	} else {
-		ret = method.Call(nil)
+		pv[i] = v
 	}
+	return pv, nil
+}
 
+func nillable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+// callValue 调用方法，调用方需保证参数已校验
+func callValue(method reflect.Value, in []reflect.Value) []interface{} {
+	ret := method.Call(in)
 	if len(ret) > 0 {
 		results := make([]interface{}, len(ret))
 		for i, v := range ret {
 			results[i] = v.Interface()
 		}
-		return results, nil
+		return results
 	}
-	return nil, nil
+	return nil
 }
 
 type defaultInvocation struct {
-	name   string
-	method reflect.Value
+	method     reflect.Method
+	targetType reflect.Type
+	fn         reflect.Value
 }
 
+// Invoke 参数校验失败时以ArityError或ArgumentTypeError panic
 func (i *defaultInvocation) Invoke(params []interface{}) []interface{} {
-	ret, err := call(i.method, params...)
+	ret, err := call(i.method.Name, i.fn, params...)
 	if err != nil {
 		panic(err)
 	}
@@ -142,13 +224,22 @@ func (i *defaultInvocation) Invoke(params []interface{}) []interface{} {
 }
 
 func (i *defaultInvocation) MethodName() string {
-	return i.name
+	return i.method.Name
+}
+
+func (i *defaultInvocation) Method() reflect.Method {
+	return i.method
+}
+
+func (i *defaultInvocation) TargetType() reflect.Type {
+	return i.targetType
 }
 
-func newInvocation(name string, method reflect.Value) *defaultInvocation {
+func newInvocation(method reflect.Method, targetType reflect.Type, fn reflect.Value) *defaultInvocation {
 	return &defaultInvocation{
-		name:   name,
-		method: method,
+		method:     method,
+		targetType: targetType,
+		fn:         fn,
 	}
 }
 
@@ -0,0 +1,229 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aop
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xfali/aop/methodfunc"
+	"reflect"
+)
+
+var (
+	// ErrMethodNotFound 目标对象不存在该方法
+	ErrMethodNotFound = errors.New("aop: method not found")
+	// ErrArityMismatch 参数数量与方法声明不一致
+	ErrArityMismatch = errors.New("aop: arity mismatch")
+	// ErrArgumentType 参数类型与方法声明不一致
+	ErrArgumentType = errors.New("aop: argument type mismatch")
+	// ErrTargetPanic 目标方法或通知发生panic
+	ErrTargetPanic = errors.New("aop: target panic")
+	// ErrResultMismatch 方法结果无法赋值至接收结果的指针，或通知返回的结果数量与方法声明不一致
+	ErrResultMismatch = errors.New("aop: result mismatch")
+	// ErrSignatureMismatch 方法签名与类型化通知不匹配
+	ErrSignatureMismatch = errors.New("aop: signature mismatch")
+)
+
+type proxyError interface {
+	proxyError()
+}
+
+// IsProxyError 判断错误是否由代理产生（查找方法、参数校验失败或panic），而非方法返回的业务错误
+func IsProxyError(err error) bool {
+	var pe proxyError
+	return errors.As(err, &pe)
+}
+
+// MethodNotFoundError 查找方法失败，可通过errors.Is(err, ErrMethodNotFound)判断
+type MethodNotFoundError struct {
+	Type   reflect.Type
+	Method string
+}
+
+func (e *MethodNotFoundError) Error() string {
+	return fmt.Sprintf("aop: cannot find method %s of type %s", e.Method, e.Type.String())
+}
+
+func (e *MethodNotFoundError) proxyError() {}
+
+func (e *MethodNotFoundError) Is(target error) bool {
+	return target == ErrMethodNotFound
+}
+
+// ArityError 参数数量错误，可通过errors.Is(err, ErrArityMismatch)判断
+type ArityError struct {
+	Method   string
+	Expect   int
+	Actual   int
+	Variadic bool
+}
+
+func (e *ArityError) Error() string {
+	if e.Variadic {
+		return fmt.Sprintf("aop: method %s expect at least %d params but get %d", e.Method, e.Expect, e.Actual)
+	}
+	return fmt.Sprintf("aop: method %s expect %d params but get %d", e.Method, e.Expect, e.Actual)
+}
+
+func (e *ArityError) proxyError() {}
+
+func (e *ArityError) Is(target error) bool {
+	return target == ErrArityMismatch
+}
+
+// ArgumentTypeError 参数类型错误，可通过errors.Is(err, ErrArgumentType)判断
+type ArgumentTypeError struct {
+	Method string
+	Index  int
+	Expect reflect.Type
+	// Actual 实际参数类型，参数为nil时为nil
+	Actual reflect.Type
+}
+
+func (e *ArgumentTypeError) Error() string {
+	actual := "nil"
+	if e.Actual != nil {
+		actual = e.Actual.String()
+	}
+	return fmt.Sprintf("aop: method %s param %d expect type %s but get %s", e.Method, e.Index, e.Expect.String(), actual)
+}
+
+func (e *ArgumentTypeError) proxyError() {}
+
+func (e *ArgumentTypeError) Is(target error) bool {
+	return target == ErrArgumentType
+}
+
+// PanicError 目标方法或通知发生panic，可通过errors.Is(err, ErrTargetPanic)判断
+type PanicError struct {
+	Method string
+	// Value panic的值
+	Value interface{}
+	// Stack 发生panic时的调用栈
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("aop: method %s panic: %v", e.Method, e.Value)
+}
+
+func (e *PanicError) proxyError() {}
+
+func (e *PanicError) Is(target error) bool {
+	return target == ErrTargetPanic
+}
+
+// Unwrap panic的值为error时返回该error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// ResultCountError 结果数量与接收结果的指针数量不一致，可通过errors.Is(err, ErrResultMismatch)判断
+type ResultCountError struct {
+	Method string
+	Expect int
+	Actual int
+}
+
+func (e *ResultCountError) Error() string {
+	return fmt.Sprintf("aop: method %s return %d results but get %d destinations", e.Method, e.Expect, e.Actual)
+}
+
+func (e *ResultCountError) proxyError() {}
+
+func (e *ResultCountError) Is(target error) bool {
+	return target == ErrResultMismatch
+}
+
+// ResultTypeError 结果无法赋值至接收结果的指针，可通过errors.Is(err, ErrResultMismatch)判断
+type ResultTypeError struct {
+	Method string
+	Index  int
+	// Expect 接收结果的指针类型
+	Expect reflect.Type
+	// Actual 结果类型，接收结果的参数不是非nil指针时为nil
+	Actual reflect.Type
+}
+
+func (e *ResultTypeError) Error() string {
+	if e.Actual == nil {
+		return fmt.Sprintf("aop: method %s result %d destination must be a non-nil pointer", e.Method, e.Index)
+	}
+	return fmt.Sprintf("aop: method %s result %d of type %s is not assignable to %s", e.Method, e.Index, e.Actual.String(), e.Expect.String())
+}
+
+func (e *ResultTypeError) proxyError() {}
+
+func (e *ResultTypeError) Is(target error) bool {
+	return target == ErrResultMismatch
+}
+
+// SignatureError 方法签名与类型化通知不匹配，可通过errors.Is(err, ErrSignatureMismatch)判断
+type SignatureError struct {
+	Method string
+	// Type 方法类型
+	Type reflect.Type
+	// Expect 期望的方法签名描述
+	Expect string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("aop: method %s with signature %s does not match %s", e.Method, e.Type.String(), e.Expect)
+}
+
+func (e *SignatureError) proxyError() {}
+
+func (e *SignatureError) Is(target error) bool {
+	return target == ErrSignatureMismatch
+}
+
+// AdviceResultError 通知返回的结果数量与方法声明不一致，可通过errors.Is(err, ErrResultMismatch)判断
+type AdviceResultError struct {
+	Method string
+	Expect int
+	Actual int
+}
+
+func (e *AdviceResultError) Error() string {
+	return fmt.Sprintf("aop: method %s declares %d results but advice returned %d", e.Method, e.Expect, e.Actual)
+}
+
+func (e *AdviceResultError) proxyError() {}
+
+func (e *AdviceResultError) Is(target error) bool {
+	return target == ErrResultMismatch
+}
+
+// liftError 方法最后一个返回值为error时将其从结果中分离，结果数量与方法声明不一致时返回*AdviceResultError
+func liftError(method string, funcType reflect.Type, ret []interface{}, err error) ([]interface{}, error) {
+	if err != nil {
+		return ret, err
+	}
+	if len(ret) != funcType.NumOut() {
+		return ret, &AdviceResultError{Method: method, Expect: funcType.NumOut(), Actual: len(ret)}
+	}
+	if !methodfunc.ReturnsError(funcType) || len(ret) == 0 {
+		return ret, nil
+	}
+	n := len(ret)
+	err, _ = ret[n-1].(error)
+	return ret[:n-1], err
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aop
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+type adviceFinder func(method reflect.Method, params ...interface{}) (Advice, Invocation)
+
+type compiledAdvice struct {
+	version    uint32
+	advice     Advice
+	invocation Invocation
+}
+
+type methodHandle struct {
+	method  reflect.Method
+	fn      reflect.Value
+	fnType  reflect.Type
+	config  *config
+	version *uint32
+	finder  adviceFinder
+
+	compiled atomic.Value
+}
+
+func newMethodHandle(method reflect.Method, fn reflect.Value, config *config, version *uint32, finder adviceFinder) *methodHandle {
+	return &methodHandle{
+		method:  method,
+		fn:      fn,
+		fnType:  fn.Type(),
+		config:  config,
+		version: version,
+		finder:  finder,
+	}
+}
+
+func (h *methodHandle) Name() string {
+	return h.method.Name
+}
+
+func (h *methodHandle) Call(params ...interface{}) (ret []interface{}, err error) {
+	in, err := paramValues(h.method.Name, h.fnType, params)
+	if err != nil {
+		return nil, err
+	}
+	defer h.config.recoverCall(h.method.Name, h.fnType, &ret, &err)
+	c := h.compile(params)
+	if c.advice == nil {
+		return callValue(h.fn, in), nil
+	}
+	return c.advice(c.invocation, params), nil
+}
+
+func (h *methodHandle) CallE(params ...interface{}) (ret []interface{}, err error) {
+	ret, err = h.Call(params...)
+	return liftError(h.method.Name, h.fnType, ret, err)
+}
+
+func (h *methodHandle) CallInto(dests []interface{}, params ...interface{}) error {
+	ret, err := h.CallE(params...)
+	return callInto(h.method.Name, dests, ret, err)
+}
+
+// compile 首次调用时编译通知链并缓存，代理新增通知后重新编译
+func (h *methodHandle) compile(params []interface{}) *compiledAdvice {
+	v := atomic.LoadUint32(h.version)
+	if c, ok := h.compiled.Load().(*compiledAdvice); ok && c.version == v {
+		return c
+	}
+	advice, invocation := h.finder(h.method, params...)
+	c := &compiledAdvice{
+		version:    v,
+		advice:     advice,
+		invocation: invocation,
+	}
+	h.compiled.Store(c)
+	return c
+}
@@ -16,9 +16,104 @@
 
 package methodfunc
 
-import "reflect"
+import (
+	"context"
+	"reflect"
+)
 
 func CheckMethod(method reflect.Method, instanceType reflect.Type, params []interface{}) bool {
 	mt := instanceType.Method(method.Index)
 	return mt.Name == method.Name && mt.Type == method.Type && mt.PkgPath == method.PkgPath
 }
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// ReturnsError 判断方法最后一个返回值是否为error
+func ReturnsError(funcType reflect.Type) bool {
+	n := funcType.NumOut()
+	return n > 0 && funcType.Out(n-1) == errorType
+}
+
+// ZeroResults 按方法声明的返回值类型构造零值结果，最后一个返回值为error时填充err
+func ZeroResults(funcType reflect.Type, err error) []interface{} {
+	n := funcType.NumOut()
+	if n == 0 {
+		return nil
+	}
+	ret := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		ret[i] = reflect.Zero(funcType.Out(i)).Interface()
+	}
+	if err != nil && ReturnsError(funcType) {
+		ret[n-1] = err
+	}
+	return ret
+}
+
+// ErrorResults 方法最后一个返回值为error时返回以err填充该返回值的零值结果，否则以err panic，
+// 用于通知不调用目标方法直接返回错误
+func ErrorResults(funcType reflect.Type, err error) []interface{} {
+	if !ReturnsError(funcType) {
+		panic(err)
+	}
+	return ZeroResults(funcType, err)
+}
+
+// FindContext 返回参数中第一个context.Context及其位置，不存在时返回context.Background()和-1
+func FindContext(params []interface{}) (context.Context, int) {
+	for i, p := range params {
+		if ctx, ok := p.(context.Context); ok && ctx != nil {
+			return ctx, i
+		}
+	}
+	return context.Background(), -1
+}
+
+// TrailingError 方法最后一个返回值为error时返回该error
+func TrailingError(funcType reflect.Type, ret []interface{}) error {
+	if !ReturnsError(funcType) || len(ret) == 0 {
+		return nil
+	}
+	err, _ := ret[len(ret)-1].(error)
+	return err
+}
+
+// ParamTypes 返回方法参数类型，不包含接收者
+func ParamTypes(method reflect.Method) []reflect.Type {
+	t := method.Type
+	offset := 0
+	// 从具体类型获取的方法第一个参数为接收者
+	if method.Func.IsValid() {
+		offset = 1
+	}
+	ret := make([]reflect.Type, 0, t.NumIn()-offset)
+	for i := offset; i < t.NumIn(); i++ {
+		ret = append(ret, t.In(i))
+	}
+	return ret
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// ContextIndex 返回方法第一个context.Context参数的位置（不包含接收者），不存在时返回-1
+func ContextIndex(method reflect.Method) int {
+	for i, t := range ParamTypes(method) {
+		if t == contextType {
+			return i
+		}
+	}
+	return -1
+}
+
+// MethodContext 返回方法声明的第一个context.Context参数及其位置，参数为nil时返回context.Background()，
+// 方法未声明context.Context参数时返回context.Background()和-1
+func MethodContext(method reflect.Method, params []interface{}) (context.Context, int) {
+	index := ContextIndex(method)
+	if index < 0 || index >= len(params) {
+		return context.Background(), -1
+	}
+	if ctx, ok := params[index].(context.Context); ok && ctx != nil {
+		return ctx, index
+	}
+	return context.Background(), index
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aop
+
+import (
+	"github.com/xfali/aop/methodfunc"
+	"reflect"
+	"runtime/debug"
+)
+
+type RecoverPolicy int
+
+const (
+	// RecoverPanic 不恢复，panic继续向上抛出（默认）
+	RecoverPanic RecoverPolicy = iota
+	// RecoverError 恢复panic，并转换为Call返回的err（*PanicError，附带调用栈）
+	RecoverError
+	// RecoverResult 恢复panic，方法最后一个返回值为error时将*PanicError填充至该返回值，其他返回值为零值；
+	// 否则同RecoverError
+	RecoverResult
+)
+
+type config struct {
+	recoverPolicy RecoverPolicy
+}
+
+type Opt func(c *config)
+
+// OptSetRecoverPolicy 设置目标方法或通知panic时的恢复策略
+func OptSetRecoverPolicy(policy RecoverPolicy) Opt {
+	return func(c *config) {
+		c.recoverPolicy = policy
+	}
+}
+
+func (c *config) apply(opts []Opt) {
+	for _, opt := range opts {
+		opt(c)
+	}
+}
+
+// recoverCall 按恢复策略处理panic，需直接使用defer调用
+func (c *config) recoverCall(method string, funcType reflect.Type, ret *[]interface{}, err *error) {
+	if c.recoverPolicy == RecoverPanic {
+		return
+	}
+	r := recover()
+	if r == nil {
+		return
+	}
+	perr, ok := r.(*PanicError)
+	if !ok {
+		perr = &PanicError{Method: method, Value: r, Stack: debug.Stack()}
+	}
+	if c.recoverPolicy == RecoverResult && methodfunc.ReturnsError(funcType) {
+		*ret = methodfunc.ZeroResults(funcType, perr)
+		*err = nil
+		return
+	}
+	*ret = nil
+	*err = perr
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aop
+
+import "reflect"
+
+func callInto(method string, dests []interface{}, ret []interface{}, err error) error {
+	if err != nil && IsProxyError(err) {
+		return err
+	}
+	if aerr := assignResults(method, dests, ret); aerr != nil {
+		return aerr
+	}
+	return err
+}
+
+// assignResults 将结果依次赋值至dests中的指针，先校验全部结果再赋值
+func assignResults(method string, dests []interface{}, ret []interface{}) error {
+	if len(dests) != len(ret) {
+		return &ResultCountError{Method: method, Expect: len(ret), Actual: len(dests)}
+	}
+	values := make([]reflect.Value, len(dests))
+	for i, dest := range dests {
+		if dest == nil {
+			continue
+		}
+		dv := reflect.ValueOf(dest)
+		if dv.Kind() != reflect.Ptr || dv.IsNil() {
+			return &ResultTypeError{Method: method, Index: i, Expect: dv.Type()}
+		}
+		et := dv.Type().Elem()
+		if ret[i] == nil {
+			values[i] = reflect.Zero(et)
+			continue
+		}
+		rv := reflect.ValueOf(ret[i])
+		if !rv.Type().AssignableTo(et) {
+			return &ResultTypeError{Method: method, Index: i, Expect: dv.Type(), Actual: rv.Type()}
+		}
+		values[i] = rv
+	}
+	for i, dest := range dests {
+		if dest != nil {
+			reflect.ValueOf(dest).Elem().Set(values[i])
+		}
+	}
+	return nil
+}
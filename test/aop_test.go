@@ -370,3 +370,34 @@ func TestChainRegExp(t *testing.T) {
 		t.Fatal("expect 10 but get ", v[1].(int))
 	}
 }
+
+type customInvocation struct{}
+
+func (i customInvocation) Invoke(params []interface{}) []interface{} {
+	return nil
+}
+
+func (i customInvocation) MethodName() string {
+	return "Custom"
+}
+
+func TestJoinPointOf(t *testing.T) {
+	var method reflect.Method
+	var typ reflect.Type
+	p := aop.New(&testStruct{})
+	p.AddAdvisor(aop.PointCutRegExp("", "AGet", nil, nil), func(invocation aop.Invocation, params []interface{}) []interface{} {
+		jp := aop.JoinPointOf(invocation)
+		method, typ = jp.Method(), jp.TargetType()
+		return invocation.Invoke(params)
+	})
+	p.Call("AGet", "x")
+	if method.Name != "AGet" || method.Type.NumIn() != 2 || typ != reflect.TypeOf(&testStruct{}) {
+		t.Fatal("unexpected join point ", method, typ)
+	}
+
+	// 未实现JoinPoint的Invocation
+	jp := aop.JoinPointOf(customInvocation{})
+	if jp.Method().Name != "Custom" || jp.Method().Type.NumOut() != 0 || jp.TargetType() != reflect.TypeOf(customInvocation{}) {
+		t.Fatal("unexpected fallback join point ", jp.Method(), jp.TargetType())
+	}
+}
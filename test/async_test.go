@@ -0,0 +1,152 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/async"
+	"testing"
+	"time"
+)
+
+type jobService struct {
+	started chan struct{}
+}
+
+func (s *jobService) Square(n int) (int, error) {
+	if n < 0 {
+		return 0, errors.New("negative")
+	}
+	return n * n, nil
+}
+
+func (s *jobService) Wait(ctx context.Context) error {
+	s.started <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *jobService) Handle(c *jobCtx) error {
+	<-c.Done()
+	return c.Err()
+}
+
+type jobCtx struct {
+	context.Context
+}
+
+func (s *jobService) Boom() {
+	panic("boom")
+}
+
+func callFuture(t *testing.T, p aop.Proxy, method string, params ...interface{}) *async.Future {
+	ret, err := p.Call(method, params...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := async.FromResult(ret)
+	if !ok {
+		t.Fatal("expect future but get ", ret)
+	}
+	return f
+}
+
+func TestAsync(t *testing.T) {
+	e := async.NewExecutor(2, 4)
+	defer e.Close()
+	p := aop.New(&jobService{started: make(chan struct{}, 1)})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), e.Advice())
+
+	f := callFuture(t, p, "Square", 3)
+	ret, err := f.Await(context.Background())
+	if err != nil || ret[0].(int) != 9 {
+		t.Fatal("expect 9 but get ", ret, err)
+	}
+	if _, err := callFuture(t, p, "Square", -1).Await(context.Background()); err == nil || err.Error() != "negative" {
+		t.Fatal("expect trailing error but get ", err)
+	}
+
+	// 同步调用方式无法获取Future，返回错误而非丢弃结果
+	var aerr *aop.AdviceResultError
+	if _, err := p.CallE("Square", 3); !errors.As(err, &aerr) {
+		t.Fatal("expect advice result error but get ", err)
+	}
+	if _, err := aop.Call1[int](p, "Square", 3); !errors.As(err, &aerr) {
+		t.Fatal("expect advice result error but get ", err)
+	}
+
+	// 具体类型实现context.Context的参数原样传递
+	ctx, cancel := context.WithCancel(context.Background())
+	f = callFuture(t, p, "Handle", &jobCtx{Context: ctx})
+	cancel()
+	if _, err := f.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatal("expect canceled by caller context but get ", err)
+	}
+
+	_, err = callFuture(t, p, "Boom").Await(context.Background())
+	var perr *aop.PanicError
+	if !errors.As(err, &perr) || perr.Value != "boom" || perr.Method != "Boom" {
+		t.Fatal("expect panic error but get ", err)
+	}
+}
+
+func TestAsyncCancel(t *testing.T) {
+	o := &jobService{started: make(chan struct{})}
+	e := async.NewExecutor(1, 1)
+	defer e.Close()
+	p := aop.New(o)
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), e.Advice())
+
+	running := callFuture(t, p, "Wait", context.Background())
+	<-o.started
+	queued := callFuture(t, p, "Square", 2)
+	if _, err := callFuture(t, p, "Square", 3).Await(context.Background()); !errors.Is(err, async.ErrQueueFull) {
+		t.Fatal("expect queue full but get ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := running.Await(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expect await timeout but get ", err)
+	}
+
+	queued.Cancel()
+	if _, err := queued.Await(context.Background()); !errors.Is(err, async.ErrCanceled) {
+		t.Fatal("expect canceled but get ", err)
+	}
+	running.Cancel()
+	if _, err := running.Await(context.Background()); !errors.Is(err, async.ErrCanceled) {
+		t.Fatal("expect canceled but get ", err)
+	}
+
+	// 取消传递至目标方法，工作协程可继续执行
+	for e.Pending() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ret, err := callFuture(t, p, "Square", 4).Await(context.Background())
+	if err != nil || ret[0].(int) != 16 {
+		t.Fatal("expect 16 but get ", ret, err)
+	}
+
+	e.Close()
+	if _, err := callFuture(t, p, "Square", 5).Await(context.Background()); !errors.Is(err, async.ErrClosed) {
+		t.Fatal("expect closed but get ", err)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/audit"
+	"github.com/xfali/aop/aspects/auth"
+	"github.com/xfali/aop/aspects/logging"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type transferReq struct {
+	To     string
+	Amount int
+	PIN    string `log:"sensitive"`
+}
+
+type bankService struct{}
+
+func (s *bankService) Transfer(ctx context.Context, req transferReq) (string, error) {
+	if req.Amount <= 0 {
+		return "", errors.New("invalid amount")
+	}
+	return "tx-1", nil
+}
+
+func (s *bankService) BatchTransfer(ctx context.Context, reqs []transferReq, byUser map[string]transferReq) error {
+	return nil
+}
+
+type failingAuditSink struct{}
+
+func (failingAuditSink) Write(r *audit.Record) error {
+	return errors.New("disk full")
+}
+
+func readAuditRecords(t *testing.T, path string) []map[string]interface{} {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var ret []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+func TestAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := audit.NewFileSink(path, audit.OptSetSync(audit.SyncEveryRecord, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	p := aop.New(&bankService{})
+	p.AddAdvisor(aop.PointCutRegExp("", "Transfer", nil, nil), audit.New(sink))
+
+	ctx := auth.WithPrincipal(context.Background(), &auth.User{ID: "tom"})
+	if _, err := p.CallE("Transfer", ctx, transferReq{To: "jerry", Amount: 10, PIN: "1234"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CallE("Transfer", ctx, transferReq{To: "jerry"}); err == nil {
+		t.Fatal("expect error")
+	}
+
+	records := readAuditRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("expect 2 records, got %d", len(records))
+	}
+	r := records[0]
+	if r["principal"] != "tom" || r["method"] != "Transfer" || r["type"] != "*test.bankService" || r["outcome"] != audit.OutcomeSuccess {
+		t.Fatal("unexpected record", r)
+	}
+	args := r["args"].([]interface{})
+	if len(args) != 1 {
+		t.Fatal("expect context skipped", args)
+	}
+	req := args[0].(map[string]interface{})
+	if req["PIN"] != logging.Redacted || req["To"] != "jerry" {
+		t.Fatal("expect PIN redacted", req)
+	}
+	if results := r["results"].([]interface{}); len(results) != 1 || results[0] != "string" {
+		t.Fatal("expect result types summary", results)
+	}
+	if records[1]["outcome"] != audit.OutcomeError || records[1]["error"] != "invalid amount" {
+		t.Fatal("unexpected error record", records[1])
+	}
+}
+
+func TestAuditRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	sink, err := audit.NewFileSink(path, audit.OptSetMaxSize(100), audit.OptSetSync(audit.SyncInterval, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := aop.New(&bankService{})
+	p.AddAdvisor(aop.PointCutRegExp("", "Transfer", nil, nil), audit.New(sink, audit.OptSetSensitiveParams("Transfer", 1)))
+	for i := 0; i < 3; i++ {
+		p.CallE("Transfer", context.Background(), transferReq{To: "jerry", Amount: 10})
+	}
+	sink.Close()
+
+	files, _ := filepath.Glob(path + "*")
+	if len(files) != 3 {
+		t.Fatalf("expect 3 files after rotation, got %v", files)
+	}
+	records := readAuditRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expect 1 record in current file, got %d", len(records))
+	}
+	if args := records[0]["args"].([]interface{}); args[0] != logging.Redacted {
+		t.Fatal("expect param redacted", args)
+	}
+	if _, ok := records[0]["principal"]; ok {
+		t.Fatal("expect no principal")
+	}
+
+	// 重新打开时追加写入
+	sink, err = audit.NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = aop.New(&bankService{})
+	p.AddAdvisor(aop.PointCutRegExp("", "Transfer", nil, nil), audit.New(sink))
+	p.CallE("Transfer", context.Background(), transferReq{To: "jerry", Amount: 10})
+	sink.Close()
+	if records := readAuditRecords(t, path); len(records) != 2 {
+		t.Fatalf("expect append, got %d records", len(records))
+	}
+}
+
+func TestAuditRedactContainers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := audit.NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := aop.New(&bankService{})
+	p.AddAdvisor(aop.PointCutRegExp("", "BatchTransfer", nil, nil), audit.New(sink))
+	p.CallE("BatchTransfer", context.Background(),
+		[]transferReq{{To: "jerry", Amount: 1, PIN: "1234"}},
+		map[string]transferReq{"tom": {To: "jerry", Amount: 2, PIN: "5678"}})
+	sink.Close()
+
+	records := readAuditRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expect 1 record, got %d", len(records))
+	}
+	args := records[0]["args"].([]interface{})
+	reqs := []interface{}{
+		args[0].([]interface{})[0],
+		args[1].(map[string]interface{})["tom"],
+	}
+	for _, v := range reqs {
+		req := v.(map[string]interface{})
+		if req["PIN"] != logging.Redacted || req["To"] != "jerry" {
+			t.Fatal("expect PIN in containers redacted", req)
+		}
+	}
+}
+
+func TestAuditRotateFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := audit.NewFileSink(path, audit.OptSetMaxSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	r := &audit.Record{Method: "Transfer", Outcome: audit.OutcomeSuccess}
+	if err := sink.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	// 文件被外部删除，轮转时重命名失败
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(r); err == nil {
+		t.Fatal("expect rotate error")
+	}
+	if err := sink.Write(r); err != nil {
+		t.Fatal("expect writes to continue after rotate failure but get ", err)
+	}
+	// 重新打开的文件写入一条记录后正常轮转
+	files, _ := filepath.Glob(path + "*")
+	if len(files) != 2 {
+		t.Fatalf("expect reopened file rotated, got %v", files)
+	}
+	if records := readAuditRecords(t, path); len(records) != 1 {
+		t.Fatalf("expect 1 record in current file, got %d", len(records))
+	}
+}
+
+func TestAuditWriteError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, nil)))
+	defer slog.SetDefault(old)
+
+	p := aop.New(&bankService{})
+	p.AddAdvisor(aop.PointCutRegExp("", "Transfer", nil, nil), audit.New(failingAuditSink{}))
+	p.CallE("Transfer", context.Background(), transferReq{To: "jerry", Amount: 10})
+	if !strings.Contains(buf.String(), "disk full") || !strings.Contains(buf.String(), "method=Transfer") {
+		t.Fatal("expect write error logged by default but get ", buf.String())
+	}
+}
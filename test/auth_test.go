@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/auth"
+	"testing"
+)
+
+type docService struct {
+	deleted int
+}
+
+func (s *docService) Read(ctx context.Context, id int) (string, error) {
+	return "doc", nil
+}
+
+func (s *docService) Delete(ctx context.Context, id int) error {
+	s.deleted++
+	return nil
+}
+
+func (s *docService) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *docService) Attach(note interface{}, ctx context.Context) error {
+	return nil
+}
+
+func TestAuth(t *testing.T) {
+	o := &docService{}
+	p := aop.New(o)
+	var denials []auth.Denial
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), auth.New(auth.Policy{
+		"Ping":                    {Anonymous: true},
+		"Read":                    {},
+		"*test.docService.Delete": {Roles: []string{"admin", "owner"}, Permissions: []string{"doc:delete"}},
+	}, auth.OptSetOnDeny(func(d auth.Denial) {
+		denials = append(denials, d)
+	})))
+
+	user := auth.WithPrincipal(context.Background(), &auth.User{ID: "tom", Roles: []string{"user"}})
+	admin := auth.WithPrincipal(context.Background(), &auth.User{ID: "root", Roles: []string{"admin"}, Permissions: []string{"doc:delete"}})
+
+	if _, err := p.CallE("Ping", context.Background()); err != nil {
+		t.Fatal("expect anonymous allowed but get ", err)
+	}
+	_, err := p.CallE("Read", context.Background(), 1)
+	if !errors.Is(err, auth.ErrForbidden) {
+		t.Fatal("expect ErrForbidden but get ", err)
+	}
+	if _, err = p.CallE("Read", user, 1); err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+
+	_, err = p.CallE("Delete", user, 1)
+	var fe *auth.ForbiddenError
+	if !errors.As(err, &fe) || fe.Principal != "tom" {
+		t.Fatal("expect forbidden for tom but get ", err)
+	}
+	if o.deleted != 0 {
+		t.Fatal("expect target not invoked")
+	}
+	if _, err = p.CallE("Delete", admin, 1); err != nil || o.deleted != 1 {
+		t.Fatal("expect deleted but get ", err)
+	}
+
+	if len(denials) != 2 || denials[1].Method != "Delete" || denials[1].Principal.Name() != "tom" {
+		t.Fatal("expect 2 denials but get ", denials)
+	}
+}
+
+func TestAuthDeclaredContext(t *testing.T) {
+	p := aop.New(&docService{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), auth.New(auth.Policy{
+		"Attach": {Roles: []string{"admin"}},
+	}))
+	admin := auth.WithPrincipal(context.Background(), &auth.User{ID: "root", Roles: []string{"admin"}})
+
+	// 主体只从声明为context.Context的参数获取
+	if _, err := p.CallE("Attach", admin, context.Background()); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatal("expect ErrForbidden but get ", err)
+	}
+	if _, err := p.CallE("Attach", "note", admin); err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/bulkhead"
+	"sync"
+	"testing"
+	"time"
+)
+
+type blockStruct struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t *blockStruct) Read(id int) error {
+	t.started <- struct{}{}
+	<-t.release
+	return nil
+}
+
+func (t *blockStruct) Write(id int) error {
+	return t.Read(id)
+}
+
+func TestBulkhead(t *testing.T) {
+	o := &blockStruct{started: make(chan struct{}, 10), release: make(chan struct{})}
+	p := aop.New(o)
+	var rejects []bulkhead.Reason
+	lock := sync.Mutex{}
+	timedOut := make(chan struct{}, 1)
+	b := bulkhead.New(2,
+		bulkhead.OptSetGroup("db", "Read", "Write"),
+		bulkhead.OptSetMaxQueue(1),
+		bulkhead.OptSetQueueTimeout(20*time.Millisecond),
+		bulkhead.OptSetOnReject(func(group string, reason bulkhead.Reason) {
+			lock.Lock()
+			defer lock.Unlock()
+			rejects = append(rejects, reason)
+			if reason == bulkhead.QueueTimeout {
+				timedOut <- struct{}{}
+			}
+		}))
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), b.Advice())
+
+	wg := sync.WaitGroup{}
+	for _, m := range []string{"Read", "Write"} {
+		wg.Add(1)
+		go func(m string) {
+			defer wg.Done()
+			p.CallE(m, 1)
+		}(m)
+	}
+	<-o.started
+	<-o.started
+	if b.Stats("db").InFlight != 2 {
+		t.Fatal("expect 2 in flight but get ", b.Stats("db"))
+	}
+
+	// 第3个调用排队等待超时
+	wg.Add(1)
+	var queuedErr error
+	go func() {
+		defer wg.Done()
+		_, queuedErr = p.CallE("Read", 3)
+	}()
+	for b.Stats("db").Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// 队列已满，第4个调用立即拒绝
+	_, err := p.CallE("Read", 4)
+	var re *bulkhead.RejectedError
+	if !errors.As(err, &re) || re.Reason != bulkhead.QueueFull || re.Group != "db" {
+		t.Fatal("expect queue full but get ", err)
+	}
+
+	// 等待排队的调用超时后再释放执行中的调用
+	<-timedOut
+	close(o.release)
+	wg.Wait()
+	if !errors.Is(queuedErr, bulkhead.ErrRejected) {
+		t.Fatal("expect queue timeout but get ", queuedErr)
+	}
+	stats := b.Stats("db")
+	if stats.Rejected != 2 || stats.Timeouts != 1 || stats.InFlight != 0 || len(rejects) != 2 {
+		t.Fatal("expect 2 rejected and 1 timeout but get ", stats, rejects)
+	}
+}
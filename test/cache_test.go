@@ -0,0 +1,190 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/cache"
+	"testing"
+	"time"
+)
+
+type userRepo struct {
+	users map[int]string
+	gets  int
+}
+
+func (r *userRepo) GetUser(id int) (string, error) {
+	r.gets++
+	if v, ok := r.users[id]; ok {
+		return v, nil
+	}
+	return "", errEmpty
+}
+
+func (r *userRepo) SaveUser(id int, name string) error {
+	r.users[id] = name
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	o := &userRepo{users: map[int]string{1: "tom"}}
+	p := aop.New(o)
+	c := cache.New(cache.OptSetTTL(20*time.Millisecond), cache.OptSetMaxEntries(2))
+	p.AddAdvisor(aop.PointCutRegExp("", "^Get", nil, nil), c.Advice())
+	p.AddAdvisor(aop.PointCutRegExp("", "^Save", nil, nil), c.EvictAdvice("GetUser"))
+
+	for i := 0; i < 3; i++ {
+		v, err := p.CallE("GetUser", 1)
+		if err != nil || v[0].(string) != "tom" {
+			t.Fatal("expect tom but get ", v, err)
+		}
+	}
+	if o.gets != 1 {
+		t.Fatal("expect 1 get but get ", o.gets)
+	}
+
+	// 不缓存错误
+	p.CallE("GetUser", 2)
+	p.CallE("GetUser", 2)
+	if o.gets != 3 {
+		t.Fatal("expect 3 gets but get ", o.gets)
+	}
+
+	p.CallE("SaveUser", 1, "jerry")
+	v, _ := p.CallE("GetUser", 1)
+	if v[0].(string) != "jerry" || o.gets != 4 {
+		t.Fatal("expect jerry after evict but get ", v, o.gets)
+	}
+
+	c.Invalidate(o, "GetUser", 1)
+	p.CallE("GetUser", 1)
+	if o.gets != 5 {
+		t.Fatal("expect 5 gets after invalidate but get ", o.gets)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	p.CallE("GetUser", 1)
+	if o.gets != 6 {
+		t.Fatal("expect 6 gets after expired but get ", o.gets)
+	}
+
+	// LRU
+	o.users[2], o.users[3] = "a", "b"
+	p.CallE("GetUser", 2)
+	p.CallE("GetUser", 3)
+	if c.Len() != 2 {
+		t.Fatal("expect 2 entries but get ", c.Len())
+	}
+	gets := o.gets
+	p.CallE("GetUser", 1)
+	if o.gets != gets+1 {
+		t.Fatal("expect 1 evicted by LRU")
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	o := &userRepo{users: map[int]string{}}
+	p := aop.New(o)
+	c := cache.New(cache.OptSetNegativeTTL(time.Minute))
+	p.AddAdvisor(aop.PointCutMethodName("GetUser"), c.Advice())
+	p.CallE("GetUser", 1)
+	_, err := p.CallE("GetUser", 1)
+	if err != errEmpty || o.gets != 1 {
+		t.Fatal("expect cached errEmpty but get ", err, o.gets)
+	}
+	c.InvalidateMethod(o, "GetUser")
+	p.CallE("GetUser", 1)
+	if o.gets != 2 {
+		t.Fatal("expect 2 gets but get ", o.gets)
+	}
+
+	if cache.HashKey([]interface{}{map[string]int{"a": 1, "b": 2}, &greetReq{Name: "x"}}) !=
+		cache.HashKey([]interface{}{map[string]int{"b": 2, "a": 1}, &greetReq{Name: "x"}}) {
+		t.Fatal("expect deterministic key")
+	}
+}
+
+type slowUserRepo struct {
+	userRepo
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *slowUserRepo) GetUser(id int) (string, error) {
+	v, err := r.userRepo.GetUser(id)
+	if r.started != nil {
+		r.started <- struct{}{}
+		<-r.release
+	}
+	return v, err
+}
+
+func TestCacheEvictDuringCall(t *testing.T) {
+	o := &slowUserRepo{
+		userRepo: userRepo{users: map[int]string{1: "tom"}},
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	p := aop.New(o)
+	c := cache.New()
+	p.AddAdvisor(aop.PointCutRegExp("", "^Get", nil, nil), c.Advice())
+	p.AddAdvisor(aop.PointCutRegExp("", "^Save", nil, nil), c.EvictAdvice("GetUser"))
+
+	done := make(chan string)
+	go func() {
+		v, _ := p.CallE("GetUser", 1)
+		done <- v[0].(string)
+	}()
+	// 读取旧值后、返回前清除缓存
+	<-o.started
+	p.CallE("SaveUser", 1, "jerry")
+	o.started = nil
+	close(o.release)
+	if v := <-done; v != "tom" {
+		t.Fatal("expect in-flight call to return tom but get ", v)
+	}
+	if c.Len() != 0 {
+		t.Fatal("expect stale value not cached but get entries ", c.Len())
+	}
+	if v, _ := p.CallE("GetUser", 1); v[0].(string) != "jerry" {
+		t.Fatal("expect jerry but get ", v)
+	}
+
+	// Invalidate、Purge同样生效
+	for _, invalidate := range []func(){
+		func() { c.Invalidate(o, "GetUser", 1) },
+		func() { c.Purge() },
+	} {
+		c.Purge()
+		o.started = make(chan struct{})
+		o.release = make(chan struct{})
+		go func() {
+			v, _ := p.CallE("GetUser", 1)
+			done <- v[0].(string)
+		}()
+		<-o.started
+		invalidate()
+		o.started = nil
+		close(o.release)
+		<-done
+		if c.Len() != 0 {
+			t.Fatal("expect stale value not cached but get entries ", c.Len())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"github.com/xfali/aop"
+	"testing"
+)
+
+var errEmpty = errors.New("empty")
+
+type errStruct struct{}
+
+func (t *errStruct) Get(s string) (string, error) {
+	if s == "" {
+		return "", errEmpty
+	}
+	return s, nil
+}
+
+func (t *errStruct) Check(s string) error {
+	if s == "" {
+		return errEmpty
+	}
+	return nil
+}
+
+func TestCallE(t *testing.T) {
+	p := aop.New(&errStruct{})
+	v, err := p.CallE("Get", "hello")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	if len(v) != 1 || v[0].(string) != "hello" {
+		t.Fatal("expect [hello] but get ", v)
+	}
+
+	v, err = p.CallE("Get", "")
+	if err != errEmpty {
+		t.Fatal("expect errEmpty but get ", err)
+	}
+	if aop.IsProxyError(err) {
+		t.Fatal("expect business error but get proxy error")
+	}
+
+	v, err = p.CallE("Check", "")
+	if err != errEmpty || len(v) != 0 {
+		t.Fatal("expect errEmpty but get ", v, err)
+	}
+
+	_, err = p.CallE("Get", 1)
+	if !aop.IsProxyError(err) {
+		t.Fatal("expect proxy error but get ", err)
+	}
+
+	h, err := p.Method("Get")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	v, err = h.CallE("hello")
+	if err != nil || v[0].(string) != "hello" {
+		t.Fatal("expect hello but get ", v, err)
+	}
+}
+
+func TestCallEAdviceResultCount(t *testing.T) {
+	p := aop.New(&errStruct{})
+	p.AddAdvisor(aop.PointCutRegExp("", "Get", nil, nil), func(invocation aop.Invocation, params []interface{}) []interface{} {
+		return []interface{}{"x"}
+	})
+	_, err := p.CallE("Get", "a")
+	var rerr *aop.AdviceResultError
+	if !errors.As(err, &rerr) || rerr.Expect != 2 || rerr.Actual != 1 || !errors.Is(err, aop.ErrResultMismatch) {
+		t.Fatal("expect advice result error but get ", err)
+	}
+	var s string
+	if err := p.CallInto("Get", []interface{}{&s}, "a"); !errors.As(err, &rerr) {
+		t.Fatal("expect advice result error but get ", err)
+	}
+	h, _ := p.Method("Get")
+	if _, err := h.CallE("a"); !errors.As(err, &rerr) {
+		t.Fatal("expect advice result error but get ", err)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/canary"
+	"testing"
+)
+
+type checkoutV1 struct{}
+
+func (s *checkoutV1) Version(ctx context.Context, user string) (string, error) {
+	return "v1", nil
+}
+
+func (s *checkoutV1) Legacy() string {
+	return "legacy"
+}
+
+type checkoutV2 struct{}
+
+func (s *checkoutV2) Version(ctx context.Context, user string) (string, error) {
+	return "v2", nil
+}
+
+func checkoutVersion(t *testing.T, p aop.Proxy, ctx context.Context, user string) string {
+	ret, err := p.CallE("Version", ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ret[0].(string)
+}
+
+func TestCanaryWeight(t *testing.T) {
+	r := canary.New("v1", canary.OptAddTarget("v2", &checkoutV2{}, 0), canary.OptSetSeed(1))
+	p := r.Proxy(&checkoutV1{})
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		if v := checkoutVersion(t, p, ctx, "tom"); v != "v1" {
+			t.Fatal("expect v1 with zero canary weight but get ", v)
+		}
+	}
+
+	r.SetWeight("v2", 100)
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		counts[checkoutVersion(t, p, ctx, "tom")]++
+	}
+	if counts["v2"] < 400 || counts["v2"] > 600 {
+		t.Fatal("expect about half routed to v2 but get ", counts)
+	}
+
+	r.SetWeight("v1", 0)
+	if v := checkoutVersion(t, p, ctx, "tom"); v != "v2" {
+		t.Fatal("expect v2 but get ", v)
+	}
+	if r.SetWeight("v3", 1) {
+		t.Fatal("expect unknown target")
+	}
+	r.SetWeight("v2", 0)
+	if v := checkoutVersion(t, p, ctx, "tom"); v != "v1" {
+		t.Fatal("expect base target when all weights are zero but get ", v)
+	}
+}
+
+func TestCanaryStickyAndContext(t *testing.T) {
+	var routes []canary.Route
+	r := canary.New("v1",
+		canary.OptAddTarget("v2", &checkoutV2{}, 50),
+		canary.OptSetStickyKey(func(params []interface{}) string {
+			return params[1].(string)
+		}),
+		canary.OptSetOnRoute(func(route canary.Route) {
+			routes = append(routes, route)
+		}))
+	p := r.Proxy(&checkoutV1{})
+	ctx := context.Background()
+
+	seen := map[string]bool{}
+	for _, user := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		v := checkoutVersion(t, p, ctx, user)
+		for i := 0; i < 5; i++ {
+			if checkoutVersion(t, p, ctx, user) != v {
+				t.Fatal("expect sticky routing for ", user)
+			}
+		}
+		seen[v] = true
+	}
+	if !seen["v1"] || !seen["v2"] {
+		t.Fatal("expect users spread over both targets ", seen)
+	}
+	if routes[0].Reason != canary.ReasonSticky || routes[0].Method != "Version" {
+		t.Fatal("unexpected route ", routes[0])
+	}
+
+	for _, name := range []string{"v1", "v2"} {
+		for _, user := range []string{"a", "b", "c"} {
+			if v := checkoutVersion(t, p, canary.WithTarget(ctx, name), user); v != name {
+				t.Fatalf("expect %s by context but get %s", name, v)
+			}
+		}
+	}
+	if routes[len(routes)-1].Reason != canary.ReasonContext {
+		t.Fatal("expect context route ", routes[len(routes)-1])
+	}
+
+	// 目标缺少方法
+	r = canary.New("v1", canary.OptAddTarget("v2", &checkoutV2{}, 1))
+	r.SetWeight("v1", 0)
+	p = r.Proxy(&checkoutV1{}, aop.OptSetRecoverPolicy(aop.RecoverError))
+	if _, err := p.Call("Legacy"); !errors.Is(err, aop.ErrMethodNotFound) {
+		t.Fatal("expect method not found but get ", err)
+	}
+}
+
+func TestCanaryLaterAdvisor(t *testing.T) {
+	r := canary.New("v1", canary.OptAddTarget("v2", &checkoutV2{}, 1))
+	r.SetWeight("v1", 0)
+	p := r.Proxy(&checkoutV1{})
+	h, err := p.Method("Version")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	denied := errors.New("denied")
+	calls := 0
+	p.AddAdvisor(aop.PointCutRegExp("", "Version", nil, nil), func(invocation aop.Invocation, params []interface{}) []interface{} {
+		calls++
+		if params[1].(string) == "guest" {
+			return []interface{}{"", denied}
+		}
+		return invocation.Invoke(params)
+	})
+
+	ctx := context.Background()
+	if v := checkoutVersion(t, p, ctx, "tom"); v != "v2" {
+		t.Fatal("expect v2 but get ", v)
+	}
+	if _, err := p.CallE("Version", ctx, "guest"); !errors.Is(err, denied) {
+		t.Fatal("expect advisor applied to canary target but get ", err)
+	}
+	// 添加通知前获取的方法句柄同样生效
+	if _, err := h.CallE(ctx, "guest"); !errors.Is(err, denied) {
+		t.Fatal("expect advisor applied to method handle but get ", err)
+	}
+	if calls != 3 {
+		t.Fatal("expect 3 advised calls but get ", calls)
+	}
+}
+
+func TestCanaryStickyRamp(t *testing.T) {
+	r := canary.New("v1",
+		canary.OptAddTarget("v2", &checkoutV2{}, 5),
+		canary.OptSetStickyKey(func(params []interface{}) string {
+			return params[1].(string)
+		}))
+	p := r.Proxy(&checkoutV1{})
+	ctx := context.Background()
+
+	users := make([]string, 1000)
+	for i := range users {
+		users[i] = fmt.Sprintf("user-%d", i)
+	}
+	var last map[string]bool
+	for _, weight := range []int{5, 10, 50, 100} {
+		r.SetWeight("v2", weight)
+		canaryUsers := map[string]bool{}
+		for _, user := range users {
+			if checkoutVersion(t, p, ctx, user) == "v2" {
+				canaryUsers[user] = true
+			}
+		}
+		for user := range last {
+			if !canaryUsers[user] {
+				t.Fatalf("expect %s to stay on v2 when weight raised to %d", user, weight)
+			}
+		}
+		if len(canaryUsers) <= len(last) {
+			t.Fatalf("expect more users on v2 at weight %d: %d <= %d", weight, len(canaryUsers), len(last))
+		}
+		last = canaryUsers
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/chaos"
+	"testing"
+	"time"
+)
+
+type inventoryService struct{}
+
+func (s *inventoryService) Stock(ctx context.Context, sku string) (int, error) {
+	return 10, nil
+}
+
+func (s *inventoryService) Ping() string {
+	return "pong"
+}
+
+func TestChaosSeed(t *testing.T) {
+	run := func() []bool {
+		injector := chaos.New(chaos.OptSetSeed(42), chaos.OptAddError("Stock", 0.3, nil))
+		p := aop.New(&inventoryService{})
+		p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), injector.Advice())
+		var ret []bool
+		for i := 0; i < 50; i++ {
+			_, err := p.CallE("Stock", context.Background(), "a")
+			if err != nil && !errors.Is(err, chaos.ErrInjected) {
+				t.Fatal("unexpected error ", err)
+			}
+			ret = append(ret, err != nil)
+		}
+		return ret
+	}
+	a, b := run(), run()
+	failed := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatal("expect reproducible injections with same seed")
+		}
+		if a[i] {
+			failed++
+		}
+	}
+	if failed == 0 || failed == 50 {
+		t.Fatal("expect some injected errors but get ", failed)
+	}
+}
+
+func TestChaos(t *testing.T) {
+	errDown := errors.New("down")
+	var injections []chaos.Injection
+	injector := chaos.New(
+		chaos.OptAddLatency("*test.inventoryService.Stock", 1, 20*time.Millisecond),
+		chaos.OptAddError("Stock", 1, errDown),
+		// 方法不返回error，不注入
+		chaos.OptAddError("Ping", 1, nil),
+		chaos.OptAddPanic("*", 0, nil),
+		chaos.OptSetOnInject(func(i chaos.Injection) {
+			injections = append(injections, i)
+		}),
+	)
+	p := aop.New(&inventoryService{}, aop.OptSetRecoverPolicy(aop.RecoverError))
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), injector.Advice())
+
+	now := time.Now()
+	if _, err := p.CallE("Stock", context.Background(), "a"); !errors.Is(err, errDown) {
+		t.Fatal("expect injected error but get ", err)
+	}
+	if time.Since(now) < 20*time.Millisecond {
+		t.Fatal("expect latency injected")
+	}
+	if len(injections) != 2 || injections[0].Fault.Kind != chaos.KindLatency || injections[1].Fault.Kind != chaos.KindError {
+		t.Fatal("unexpected injections ", injections)
+	}
+	if ret, err := p.Call("Ping"); err != nil || ret[0].(string) != "pong" {
+		t.Fatal("expect pong but get ", ret, err)
+	}
+
+	// 延迟在context结束时提前返回
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	now = time.Now()
+	p.CallE("Stock", ctx, "a")
+	if time.Since(now) >= 20*time.Millisecond {
+		t.Fatal("expect latency interrupted by context")
+	}
+
+	injector.Disable()
+	if ret, err := p.CallE("Stock", context.Background(), "a"); err != nil || ret[0].(int) != 10 {
+		t.Fatal("expect no injection when disabled but get ", ret, err)
+	}
+	injector.Enable()
+
+	panicky := chaos.New(chaos.OptAddPanic("Ping", 1, nil))
+	p = aop.New(&inventoryService{}, aop.OptSetRecoverPolicy(aop.RecoverError))
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), panicky.Advice())
+	var perr *aop.PanicError
+	if _, err := p.Call("Ping"); !errors.As(err, &perr) || !errors.Is(err, chaos.ErrInjected) {
+		t.Fatal("expect injected panic but get ", err)
+	}
+}
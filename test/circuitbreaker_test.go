@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/circuitbreaker"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	o := &flakyStruct{failures: 3}
+	p := aop.New(o)
+	var changes []circuitbreaker.State
+	b := circuitbreaker.New(
+		circuitbreaker.OptSetConsecutiveFailures(3),
+		circuitbreaker.OptSetCoolDown(20*time.Millisecond),
+		circuitbreaker.OptSetOnStateChange(func(key string, from, to circuitbreaker.State) {
+			changes = append(changes, to)
+		}))
+	p.AddAdvisor(aop.PointCutMethodName("Get"), b.Advice())
+	key := "*test.flakyStruct.Get"
+
+	for i := 0; i < 3; i++ {
+		_, err := p.CallE("Get", context.Background(), "hello")
+		if err != errFlaky {
+			t.Fatal("expect errFlaky but get ", err)
+		}
+	}
+	if b.State(key) != circuitbreaker.Open {
+		t.Fatal("expect open but get ", b.State(key))
+	}
+
+	v, err := p.CallE("Get", context.Background(), "hello")
+	if err != circuitbreaker.ErrOpen || v[0].(string) != "" || o.calls != 3 {
+		t.Fatal("expect ErrOpen without invoking target but get ", v, err, o.calls)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if b.State(key) != circuitbreaker.HalfOpen {
+		t.Fatal("expect half-open but get ", b.State(key))
+	}
+	v, err = p.CallE("Get", context.Background(), "hello")
+	if err != nil || v[0].(string) != "hello" {
+		t.Fatal("expect hello but get ", v, err)
+	}
+	if b.State(key) != circuitbreaker.Closed {
+		t.Fatal("expect closed but get ", b.State(key))
+	}
+	if len(changes) != 3 {
+		t.Fatal("expect open, half-open, closed but get ", changes)
+	}
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	o := &flakyStruct{failures: 100}
+	p := aop.New(o)
+	b := circuitbreaker.New(
+		circuitbreaker.OptSetConsecutiveFailures(0),
+		circuitbreaker.OptSetFailureRate(0.5, 4),
+		circuitbreaker.OptSetKeyFunc(func(invocation aop.Invocation, params []interface{}) string {
+			return params[1].(string)
+		}),
+		circuitbreaker.OptSetFallback(func(invocation aop.Invocation, params []interface{}) []interface{} {
+			return []interface{}{"fallback", nil}
+		}))
+	p.AddAdvisor(aop.PointCutMethodName("Get"), b.Advice())
+
+	for i := 0; i < 3; i++ {
+		p.CallE("Get", context.Background(), "a")
+	}
+	if b.State("a") != circuitbreaker.Closed {
+		t.Fatal("expect closed before window full but get ", b.State("a"))
+	}
+	p.CallE("Get", context.Background(), "a")
+	if b.State("a") != circuitbreaker.Open || b.State("b") != circuitbreaker.Closed {
+		t.Fatal("expect a open and b closed")
+	}
+	v, err := p.CallE("Get", context.Background(), "a")
+	if err != nil || v[0].(string) != "fallback" {
+		t.Fatal("expect fallback but get ", v, err)
+	}
+}
+
+type gatedStruct struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *gatedStruct) Get(ctx context.Context, str string) (string, error) {
+	switch str {
+	case "slow":
+		s.started <- struct{}{}
+		<-s.release
+	case "fail":
+		return "", errFlaky
+	}
+	return str, nil
+}
+
+func TestCircuitBreakerStaleOutcome(t *testing.T) {
+	o := &gatedStruct{started: make(chan struct{}), release: make(chan struct{})}
+	p := aop.New(o)
+	b := circuitbreaker.New(
+		circuitbreaker.OptSetConsecutiveFailures(2),
+		circuitbreaker.OptSetCoolDown(20*time.Millisecond))
+	p.AddAdvisor(aop.PointCutMethodName("Get"), b.Advice())
+	key := "*test.gatedStruct.Get"
+
+	// 关闭时放行的慢调用在半开状态成功结束
+	done := make(chan struct{})
+	go func() {
+		p.CallE("Get", context.Background(), "slow")
+		close(done)
+	}()
+	<-o.started
+	p.CallE("Get", context.Background(), "fail")
+	p.CallE("Get", context.Background(), "fail")
+	time.Sleep(30 * time.Millisecond)
+	if b.State(key) != circuitbreaker.HalfOpen {
+		t.Fatal("expect half-open but get ", b.State(key))
+	}
+	close(o.release)
+	<-done
+	if b.State(key) != circuitbreaker.HalfOpen {
+		t.Fatal("expect stale outcome ignored but get ", b.State(key))
+	}
+	p.CallE("Get", context.Background(), "fail")
+	if b.State(key) != circuitbreaker.Open {
+		t.Fatal("expect failed trial to open circuit but get ", b.State(key))
+	}
+}
+
+func TestCircuitBreakerEviction(t *testing.T) {
+	o := &flakyStruct{failures: 1}
+	p := aop.New(o)
+	b := circuitbreaker.New(
+		circuitbreaker.OptSetConsecutiveFailures(1),
+		circuitbreaker.OptSetCoolDown(time.Hour),
+		circuitbreaker.OptSetMaxKeys(2),
+		circuitbreaker.OptSetIdleTimeout(20*time.Millisecond),
+		circuitbreaker.OptSetKeyFunc(func(invocation aop.Invocation, params []interface{}) string {
+			return params[1].(string)
+		}))
+	p.AddAdvisor(aop.PointCutMethodName("Get"), b.Advice())
+
+	p.CallE("Get", context.Background(), "a")
+	if b.State("a") != circuitbreaker.Open {
+		t.Fatal("expect open but get ", b.State("a"))
+	}
+	// 超过key数量上限及空闲超时，打开的熔断器不会被淘汰
+	for _, key := range []string{"b", "c", "d"} {
+		p.CallE("Get", context.Background(), key)
+	}
+	time.Sleep(30 * time.Millisecond)
+	p.CallE("Get", context.Background(), "e")
+	if _, err := p.CallE("Get", context.Background(), "a"); err != circuitbreaker.ErrOpen {
+		t.Fatal("expect ErrOpen but get ", err)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"github.com/xfali/aop"
+	"testing"
+)
+
+type paramStruct struct{}
+
+func (t paramStruct) Len(s []string) int {
+	return len(s)
+}
+
+func (t paramStruct) Join(sep string, s ...string) string {
+	ret := ""
+	for i, v := range s {
+		if i > 0 {
+			ret += sep
+		}
+		ret += v
+	}
+	return ret
+}
+
+func TestCallErrors(t *testing.T) {
+	p := aop.New(&testStruct{})
+	_, err := p.Call("NotExistMethod", "?")
+	if !errors.Is(err, aop.ErrMethodNotFound) {
+		t.Fatal("expect ErrMethodNotFound but get ", err)
+	}
+	var nf *aop.MethodNotFoundError
+	if !errors.As(err, &nf) || nf.Method != "NotExistMethod" {
+		t.Fatal("expect MethodNotFoundError but get ", err)
+	}
+
+	_, err = p.Call("Concat", "hello")
+	if !errors.Is(err, aop.ErrArityMismatch) {
+		t.Fatal("expect ErrArityMismatch but get ", err)
+	}
+
+	_, err = p.Call("Concat", "hello", 1)
+	if !errors.Is(err, aop.ErrArgumentType) {
+		t.Fatal("expect ErrArgumentType but get ", err)
+	}
+	var ae *aop.ArgumentTypeError
+	if !errors.As(err, &ae) || ae.Index != 1 {
+		t.Fatal("expect ArgumentTypeError at 1 but get ", err)
+	}
+
+	_, err = p.Call("Concat", nil, "world")
+	if !errors.Is(err, aop.ErrArgumentType) {
+		t.Fatal("expect ErrArgumentType but get ", err)
+	}
+	t.Log(err)
+}
+
+func TestCallParams(t *testing.T) {
+	p := aop.New(paramStruct{})
+	v, err := p.Call("Len", nil)
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	if v[0].(int) != 0 {
+		t.Fatal("expect 0 but get ", v[0])
+	}
+
+	v, err = p.Call("Join", ",", "a", "b")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	if v[0].(string) != "a,b" {
+		t.Fatal("expect a,b but get ", v[0])
+	}
+
+	_, err = p.Call("Join")
+	if !errors.Is(err, aop.ErrArityMismatch) {
+		t.Fatal("expect ErrArityMismatch but get ", err)
+	}
+	_, err = p.Call("Join", ",", "a", 1)
+	if !errors.Is(err, aop.ErrArgumentType) {
+		t.Fatal("expect ErrArgumentType but get ", err)
+	}
+}
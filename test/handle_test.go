@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"github.com/xfali/aop"
+	"testing"
+)
+
+func TestMethodHandle(t *testing.T) {
+	proxies := map[string]aop.Proxy{
+		"simple": aop.NewSimple(&testStruct{}),
+		"chain":  aop.New(&testStruct{}),
+	}
+	for name, p := range proxies {
+		t.Run(name, func(t *testing.T) {
+			_, err := p.Method("NotExistMethod")
+			if err == nil {
+				t.Fatal("expect error but get nil")
+			}
+
+			h, err := p.Method("Concat")
+			if err != nil {
+				t.Fatal("expect nil but get ", err)
+			}
+			if h.Name() != "Concat" {
+				t.Fatal("expect Concat but get ", h.Name())
+			}
+
+			v, err := h.Call("hello", "world")
+			if err != nil {
+				t.Fatal("expect nil but get ", err)
+			}
+			if v[0].(string) != "helloworld" {
+				t.Fatal("expect helloworld but get ", v[0].(string))
+			}
+
+			_, err = h.Call("hello")
+			if err == nil {
+				t.Fatal("expect error but get nil")
+			}
+
+			// 句柄创建后新增的通知仍需生效
+			p.AddAdvisor(aop.PointCutMethodName("Concat"), func(invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+				v := invocation.Invoke(params)
+				v[0] = v[0].(string) + "r1"
+				return v
+			})
+			for i := 0; i < 2; i++ {
+				v, err = h.Call("hello", "world")
+				if err != nil {
+					t.Fatal("expect nil but get ", err)
+				}
+				if v[0].(string) != "helloworldr1" {
+					t.Fatal("expect helloworldr1 but get ", v[0].(string))
+				}
+			}
+		})
+	}
+}
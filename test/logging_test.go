@@ -0,0 +1,180 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/logging"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type loginReq struct {
+	User     string
+	Password string `log:"sensitive"`
+}
+
+type loginStruct struct{}
+
+func (t *loginStruct) Login(req loginReq, token string) (bool, error) {
+	if req.Password == "" {
+		return false, errEmpty
+	}
+	return true, nil
+}
+
+func TestLoggingJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := aop.New(&loginStruct{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), logging.New(
+		logging.OptSetSink(logging.NewJSONSink(buf)),
+		logging.OptSetSensitiveParams("Login", 1)))
+
+	_, err := p.Call("Login", loginReq{User: "tom", Password: "secret"}, "token123")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	_, err = p.Call("Login", loginReq{User: "tom"}, "token123")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal("expect 2 records but get ", len(lines))
+	}
+	t.Log(lines[0])
+	if strings.Contains(buf.String(), "secret") || strings.Contains(buf.String(), "token123") {
+		t.Fatal("expect sensitive params redacted")
+	}
+	var r map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r["method"] != "Login" || r["type"] != "*test.loginStruct" {
+		t.Fatal("expect Login of *test.loginStruct but get ", r["method"], r["type"])
+	}
+	if r["level"] != "ERROR" || r["error"] != errEmpty.Error() {
+		t.Fatal("expect ERROR level with error but get ", r["level"], r["error"])
+	}
+}
+
+func TestLoggingLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := aop.New(&testStruct{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), logging.New(
+		logging.OptSetSink(logging.NewLogfmtSink(buf)),
+		logging.OptSetMinLevel(slog.LevelInfo),
+		logging.OptSetMethodLevel("AGet", slog.LevelDebug)))
+
+	p.Call("AGet", "hello")
+	if buf.Len() != 0 {
+		t.Fatal("expect AGet filtered but get ", buf.String())
+	}
+	p.Call("Concat", "hello", "world")
+	t.Log(buf.String())
+	if !strings.Contains(buf.String(), "method=Concat") || !strings.Contains(buf.String(), "level=INFO") {
+		t.Fatal("expect Concat INFO record but get ", buf.String())
+	}
+}
+
+func TestLoggingSlog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := aop.New(&testStruct{})
+	p.AddAdvisor(aop.PointCutMethodName("Concat"), logging.New(
+		logging.OptSetSink(logging.NewSlogSink(slog.New(slog.NewTextHandler(buf, nil))))))
+	p.Call("Concat", "hello", "world")
+	t.Log(buf.String())
+	if !strings.Contains(buf.String(), "method=Concat") {
+		t.Fatal("expect Concat record but get ", buf.String())
+	}
+}
+
+func TestRedactContainers(t *testing.T) {
+	type account struct {
+		Logins []loginReq
+	}
+	cases := []interface{}{
+		[]loginReq{{User: "tom", Password: "secret"}},
+		[1]*loginReq{{User: "tom", Password: "secret"}},
+		map[string]loginReq{"tom": {User: "tom", Password: "secret"}},
+		&account{Logins: []loginReq{{User: "tom", Password: "secret"}}},
+	}
+	for _, c := range cases {
+		s := fmt.Sprint(logging.Redact(c))
+		if strings.Contains(s, "secret") || !strings.Contains(s, "tom") || !strings.Contains(s, logging.Redacted) {
+			t.Fatalf("expect %T redacted but get %s", c, s)
+		}
+	}
+	if v := logging.Redact([]string{"a"}); fmt.Sprint(v) != "[a]" {
+		t.Fatal("expect value without sensitive fields unchanged but get ", v)
+	}
+	if v := logging.Redact([]loginReq(nil)); fmt.Sprint(v) != "[]" {
+		t.Fatal("expect empty value unchanged but get ", v)
+	}
+
+	// 按动态类型判断
+	type envelope struct {
+		Body interface{}
+		Err  error
+	}
+	dynamic := []interface{}{
+		[]interface{}{"tom", loginReq{User: "tom", Password: "secret"}},
+		map[string]interface{}{"req": &loginReq{User: "tom", Password: "secret"}},
+		envelope{Body: loginReq{User: "tom", Password: "secret"}},
+	}
+	for _, c := range dynamic {
+		s := fmt.Sprint(logging.Redact(c))
+		if strings.Contains(s, "secret") || !strings.Contains(s, "tom") || !strings.Contains(s, logging.Redacted) {
+			t.Fatalf("expect %T redacted but get %s", c, s)
+		}
+	}
+	plain := envelope{Body: "tom", Err: errEmpty}
+	if v := logging.Redact(plain); v != plain {
+		t.Fatal("expect value without sensitive fields unchanged but get ", v)
+	}
+}
+
+type profileStruct struct{}
+
+func (t *profileStruct) Profile(user string) (*loginReq, error) {
+	return &loginReq{User: user, Password: "secret"}, nil
+}
+
+func TestLoggingRedactResults(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := aop.New(&profileStruct{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), logging.New(
+		logging.OptSetSink(logging.NewJSONSink(buf))))
+
+	ret, err := p.Call("Profile", "tom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret[0].(*loginReq).Password != "secret" {
+		t.Fatal("expect caller to get original result")
+	}
+	t.Log(buf.String())
+	if strings.Contains(buf.String(), "secret") || !strings.Contains(buf.String(), "tom") {
+		t.Fatal("expect sensitive results redacted but get ", buf.String())
+	}
+}
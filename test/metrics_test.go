@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/metrics"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	r := metrics.NewRegistry(metrics.OptSetBuckets(0.1, 1))
+	p := aop.New(&errStruct{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), r.Advice())
+
+	p.Call("Get", "hello")
+	p.Call("Get", "")
+	p.Call("Check", "world")
+
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	out := string(b)
+	t.Log(out)
+
+	expects := []string{
+		"# TYPE aop_calls_total counter",
+		`aop_calls_total{type="*test.errStruct",method="Get"} 2`,
+		`aop_errors_total{type="*test.errStruct",method="Get"} 1`,
+		`aop_errors_total{type="*test.errStruct",method="Check"} 0`,
+		`aop_in_flight{type="*test.errStruct",method="Get"} 0`,
+		`aop_call_duration_seconds_bucket{type="*test.errStruct",method="Get",le="+Inf"} 2`,
+		`aop_call_duration_seconds_count{type="*test.errStruct",method="Check"} 1`,
+	}
+	for _, e := range expects {
+		if !strings.Contains(out, e) {
+			t.Fatal("expect contains ", e)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/ratelimit"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type mailStruct struct {
+	sent int32
+}
+
+func (t *mailStruct) Send(ctx context.Context, to string) error {
+	atomic.AddInt32(&t.sent, 1)
+	return nil
+}
+
+func TestRateLimitReject(t *testing.T) {
+	o := &mailStruct{}
+	p := aop.New(o)
+	p.AddAdvisor(aop.PointCutMethodName("Send"), ratelimit.New(ratelimit.TokenBucket(1, 2),
+		ratelimit.OptSetKeyFunc(func(invocation aop.Invocation, params []interface{}) string {
+			return params[1].(string)
+		})))
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.CallE("Send", context.Background(), "a"); err != nil {
+			t.Fatal("expect nil but get ", err)
+		}
+	}
+	_, err := p.CallE("Send", context.Background(), "a")
+	if !errors.Is(err, ratelimit.ErrRateLimited) {
+		t.Fatal("expect ErrRateLimited but get ", err)
+	}
+	var re *ratelimit.RateLimitError
+	if !errors.As(err, &re) || re.Key != "a" || re.RetryAfter <= 0 {
+		t.Fatal("expect RateLimitError of a but get ", err)
+	}
+	if _, err = p.CallE("Send", context.Background(), "b"); err != nil {
+		t.Fatal("expect b not limited but get ", err)
+	}
+	if o.sent != 3 {
+		t.Fatal("expect 3 sent but get ", o.sent)
+	}
+}
+
+func TestRateLimitBlocking(t *testing.T) {
+	o := &mailStruct{}
+	p := aop.New(o)
+	p.AddAdvisor(aop.PointCutMethodName("Send"), ratelimit.New(ratelimit.SlidingWindow(2, 20*time.Millisecond),
+		ratelimit.OptSetBlocking(true)))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := p.CallE("Send", context.Background(), "a"); err != nil {
+			t.Fatal("expect nil but get ", err)
+		}
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatal("expect third call blocked")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	p.CallE("Send", ctx, "a")
+	_, err := p.CallE("Send", ctx, "a")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expect DeadlineExceeded but get ", err)
+	}
+}
+
+type opaqueLimiter struct {
+	ratelimit.Limiter
+}
+
+func TestRateLimitEviction(t *testing.T) {
+	created := 0
+	o := &mailStruct{}
+	p := aop.New(o)
+	// 未实现IdleLimiter的Limiter总是可以淘汰
+	p.AddAdvisor(aop.PointCutMethodName("Send"), ratelimit.New(func() ratelimit.Limiter {
+		created++
+		return opaqueLimiter{ratelimit.NewTokenBucket(0, 1)}
+	},
+		ratelimit.OptSetMaxKeys(2),
+		ratelimit.OptSetIdleTimeout(20*time.Millisecond),
+		ratelimit.OptSetKeyFunc(func(invocation aop.Invocation, params []interface{}) string {
+			return params[1].(string)
+		})))
+
+	p.CallE("Send", context.Background(), "a")
+	if _, err := p.CallE("Send", context.Background(), "a"); !errors.Is(err, ratelimit.ErrRateLimited) {
+		t.Fatal("expect ErrRateLimited but get ", err)
+	}
+	// 超过key数量上限，淘汰最久未使用的a
+	p.CallE("Send", context.Background(), "b")
+	p.CallE("Send", context.Background(), "c")
+	if _, err := p.CallE("Send", context.Background(), "a"); err != nil {
+		t.Fatal("expect a evicted by LRU cap but get ", err)
+	}
+	if created != 4 {
+		t.Fatal("expect 4 limiters created but get ", created)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := p.CallE("Send", context.Background(), "a"); err != nil {
+		t.Fatal("expect idle a evicted but get ", err)
+	}
+	if created != 5 {
+		t.Fatal("expect 5 limiters created but get ", created)
+	}
+}
+
+func TestRateLimitKeepBusyLimiter(t *testing.T) {
+	o := &mailStruct{}
+	p := aop.New(o)
+	p.AddAdvisor(aop.PointCutMethodName("Send"), ratelimit.New(ratelimit.SlidingWindow(1, time.Hour),
+		ratelimit.OptSetMaxKeys(2),
+		ratelimit.OptSetIdleTimeout(20*time.Millisecond),
+		ratelimit.OptSetKeyFunc(func(invocation aop.Invocation, params []interface{}) string {
+			return params[1].(string)
+		})))
+
+	if _, err := p.CallE("Send", context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	// 超过key数量上限，a的窗口未结束不会被淘汰
+	p.CallE("Send", context.Background(), "b")
+	p.CallE("Send", context.Background(), "c")
+	if _, err := p.CallE("Send", context.Background(), "a"); !errors.Is(err, ratelimit.ErrRateLimited) {
+		t.Fatal("expect ErrRateLimited after LRU cap but get ", err)
+	}
+	// 空闲超时，a的窗口未结束不会被淘汰
+	time.Sleep(30 * time.Millisecond)
+	if _, err := p.CallE("Send", context.Background(), "a"); !errors.Is(err, ratelimit.ErrRateLimited) {
+		t.Fatal("expect ErrRateLimited after idle timeout but get ", err)
+	}
+
+	now := time.Now()
+	for _, l := range []ratelimit.Limiter{
+		ratelimit.NewSlidingWindow(1, time.Minute),
+		ratelimit.NewTokenBucket(1, 2),
+	} {
+		il := l.(ratelimit.IdleLimiter)
+		l.TryAcquire(now)
+		if il.Idle(now.Add(time.Second / 2)) {
+			t.Fatalf("expect %T busy", l)
+		}
+		if !il.Idle(now.Add(time.Minute)) {
+			t.Fatalf("expect %T idle", l)
+		}
+	}
+}
+
+func TestRateLimitInvalidBurst(t *testing.T) {
+	for _, f := range []func(){
+		func() { ratelimit.NewTokenBucket(1, 0) },
+		func() { ratelimit.TokenBucket(1, 0) },
+		func() { ratelimit.SlidingWindow(0, time.Second) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expect panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
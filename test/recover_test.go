@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"github.com/xfali/aop"
+	"testing"
+)
+
+type panicStruct struct{}
+
+func (t *panicStruct) Panic(s string) string {
+	panic(s)
+}
+
+func (t *panicStruct) PanicE(s string) (string, error) {
+	panic(s)
+}
+
+func TestRecoverPanic(t *testing.T) {
+	p := aop.New(&panicStruct{})
+	defer func() {
+		if r := recover(); r != "test" {
+			t.Fatal("expect panic test but get ", r)
+		}
+	}()
+	p.Call("Panic", "test")
+	t.Fatal("expect panic")
+}
+
+func TestRecoverError(t *testing.T) {
+	p := aop.New(&panicStruct{}, aop.OptSetRecoverPolicy(aop.RecoverError))
+	_, err := p.Call("Panic", "test")
+	if !errors.Is(err, aop.ErrTargetPanic) {
+		t.Fatal("expect ErrTargetPanic but get ", err)
+	}
+	var pe *aop.PanicError
+	if !errors.As(err, &pe) || pe.Value != "test" || len(pe.Stack) == 0 {
+		t.Fatal("expect PanicError with stack but get ", err)
+	}
+
+	// 通知中调用参数错误
+	p.AddAdvisor(aop.PointCutMethodName("Panic"), func(invocation aop.Invocation, params []interface{}) (ret []interface{}) {
+		return invocation.Invoke([]interface{}{1})
+	})
+	h, err := p.Method("Panic")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	_, err = h.Call("test")
+	if !errors.Is(err, aop.ErrTargetPanic) || !errors.Is(err, aop.ErrArgumentType) {
+		t.Fatal("expect ErrTargetPanic and ErrArgumentType but get ", err)
+	}
+}
+
+func TestRecoverResult(t *testing.T) {
+	p := aop.NewSimple(&panicStruct{}, aop.OptSetRecoverPolicy(aop.RecoverResult))
+	v, err := p.Call("PanicE", "test")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	if v[0].(string) != "" {
+		t.Fatal("expect empty string but get ", v[0])
+	}
+	if e, ok := v[1].(error); !ok || !errors.Is(e, aop.ErrTargetPanic) {
+		t.Fatal("expect ErrTargetPanic but get ", v[1])
+	}
+
+	// 最后一个返回值非error时转换为err
+	_, err = p.Call("Panic", "test")
+	if !errors.Is(err, aop.ErrTargetPanic) {
+		t.Fatal("expect ErrTargetPanic but get ", err)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/replay"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type forecast struct {
+	City string
+	Days []string
+}
+
+type weatherService struct {
+	calls int
+}
+
+func (s *weatherService) Forecast(ctx context.Context, city string, days int) (*forecast, error) {
+	s.calls++
+	if days <= 0 {
+		return nil, errors.New("invalid days")
+	}
+	f := &forecast{City: city}
+	for i := 0; i < days; i++ {
+		f.Days = append(f.Days, "sunny")
+	}
+	return f, nil
+}
+
+func (s *weatherService) Temp(city string) float64 {
+	s.calls++
+	return 21.5
+}
+
+func recordWeather(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "weather.golden.json")
+	r := replay.NewRecorder()
+	p := aop.New(&weatherService{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), r.Advice())
+	p.CallE("Forecast", context.Background(), "paris", 2)
+	p.CallE("Forecast", context.Background(), "paris", 0)
+	p.Call("Temp", "rome")
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplayStrict(t *testing.T) {
+	path := recordWeather(t)
+	player, err := replay.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &weatherService{}
+	p := aop.New(o)
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), player.Advice())
+
+	ret, err := p.CallE("Forecast", context.Background(), "paris", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := ret[0].(*forecast); f.City != "paris" || len(f.Days) != 2 {
+		t.Fatal("unexpected forecast ", f)
+	}
+	if ret, err = p.CallE("Forecast", context.Background(), "paris", 0); err == nil || err.Error() != "invalid days" || ret[0].(*forecast) != nil {
+		t.Fatal("expect recorded error but get ", ret, err)
+	}
+	if err := player.Verify(); err == nil {
+		t.Fatal("expect unreplayed call")
+	}
+
+	// 顺序不一致
+	_, err = p.CallE("Forecast", context.Background(), "rome", 1)
+	var merr *replay.MismatchError
+	if !errors.As(err, &merr) || !errors.Is(err, replay.ErrNoMatch) {
+		t.Fatal("expect mismatch but get ", err)
+	}
+	if !strings.Contains(merr.Diff, `- *test.weatherService.Temp("rome")`) {
+		t.Fatal("expect diff against Temp call but get ", merr.Diff)
+	}
+
+	ret, err = p.Call("Temp", "rome")
+	if err != nil || ret[0].(float64) != 21.5 {
+		t.Fatal("expect 21.5 but get ", ret, err)
+	}
+	if err := player.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if o.calls != 0 {
+		t.Fatal("expect target not invoked")
+	}
+}
+
+func TestReplayLenient(t *testing.T) {
+	player, err := replay.Load(recordWeather(t), replay.OptSetMode(replay.Lenient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := aop.New(&weatherService{}, aop.OptSetRecoverPolicy(aop.RecoverError))
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), player.Advice())
+
+	for i := 0; i < 2; i++ {
+		if ret, err := p.Call("Temp", "rome"); err != nil || ret[0].(float64) != 21.5 {
+			t.Fatal("expect 21.5 but get ", ret, err)
+		}
+		if _, err := p.CallE("Forecast", context.Background(), "paris", 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = p.CallE("Forecast", context.Background(), "paris", 3)
+	var merr *replay.MismatchError
+	if !errors.As(err, &merr) {
+		t.Fatal("expect mismatch but get ", err)
+	}
+	if !strings.Contains(merr.Diff, "arg[1]:\n  - 2\n  + 3") {
+		t.Fatal("expect arg diff but get ", merr.Diff)
+	}
+
+	// 方法不返回error时panic
+	_, err = p.Call("Temp", "oslo")
+	var perr *aop.PanicError
+	if !errors.As(err, &perr) || !errors.Is(err, replay.ErrNoMatch) {
+		t.Fatal("expect panic with mismatch but get ", err)
+	}
+}
+
+type alertService struct {
+	calls int
+}
+
+func (s *alertService) Subscribe(ch chan string) int {
+	s.calls++
+	return s.calls
+}
+
+func (s *alertService) Handler(city string) (func(), error) {
+	s.calls++
+	return func() {}, nil
+}
+
+func TestRecordEncodeFailure(t *testing.T) {
+	r := replay.NewRecorder()
+	o := &alertService{}
+	p := aop.New(o)
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), r.Advice())
+
+	if ret, err := p.Call("Subscribe", make(chan string)); err != nil || ret[0].(int) != 1 {
+		t.Fatal("expect call unaffected by args encoding failure but get ", ret, err)
+	}
+	if ret, err := p.CallE("Handler", "paris"); err != nil || ret[0] == nil {
+		t.Fatal("expect call unaffected by results encoding failure but get ", ret, err)
+	}
+	if o.calls != 2 {
+		t.Fatal("expect 2 calls but get ", o.calls)
+	}
+	if len(r.Entries()) != 0 {
+		t.Fatal("expect no entries but get ", r.Entries())
+	}
+	err := r.Err()
+	if err == nil || !strings.Contains(err.Error(), "Subscribe args") || !strings.Contains(err.Error(), "Handler results") {
+		t.Fatal("expect encoding errors but get ", err)
+	}
+	if err := r.Save(filepath.Join(t.TempDir(), "alert.golden.json")); err == nil {
+		t.Fatal("expect save to fail")
+	}
+}
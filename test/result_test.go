@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"github.com/xfali/aop"
+	"testing"
+)
+
+func TestCallInto(t *testing.T) {
+	p := aop.New(&testStruct{})
+	var s string
+	var n int
+	err := p.CallInto("Concat", []interface{}{&s, &n}, "hello", "world")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	if s != "helloworld" || n != len("helloworld") {
+		t.Fatal("expect helloworld 10 but get ", s, n)
+	}
+
+	err = p.CallInto("Concat", []interface{}{&s, nil}, "a", "b")
+	if err != nil || s != "ab" {
+		t.Fatal("expect ab but get ", s, err)
+	}
+
+	err = p.CallInto("Concat", []interface{}{&s}, "a", "b")
+	if !errors.Is(err, aop.ErrResultMismatch) {
+		t.Fatal("expect ErrResultMismatch but get ", err)
+	}
+
+	err = p.CallInto("Concat", []interface{}{&n, &s}, "a", "b")
+	if !errors.Is(err, aop.ErrResultMismatch) {
+		t.Fatal("expect ErrResultMismatch but get ", err)
+	}
+	t.Log(err)
+
+	err = p.CallInto("Concat", []interface{}{s, &n}, "a", "b")
+	if !errors.Is(err, aop.ErrResultMismatch) {
+		t.Fatal("expect ErrResultMismatch but get ", err)
+	}
+	t.Log(err)
+
+	e := aop.New(&errStruct{})
+	err = e.CallInto("Get", []interface{}{&s}, "")
+	if err != errEmpty || s != "" {
+		t.Fatal("expect errEmpty but get ", s, err)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/retry"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+type flakyStruct struct {
+	failures int
+	calls    int
+}
+
+func (t *flakyStruct) Get(ctx context.Context, s string) (string, error) {
+	t.calls++
+	if t.calls <= t.failures {
+		return "", errFlaky
+	}
+	return s, nil
+}
+
+func TestRetry(t *testing.T) {
+	o := &flakyStruct{failures: 2}
+	p := aop.New(o)
+	var attempts []retry.Attempt
+	p.AddAdvisor(aop.PointCutMethodName("Get"), retry.New(
+		retry.OptSetMaxAttempts(3),
+		retry.OptSetBackoff(retry.JitterBackoff(retry.ConstantBackoff(time.Millisecond), 0.5)),
+		retry.OptSetOnAttempt(func(a retry.Attempt) {
+			attempts = append(attempts, a)
+		})))
+
+	v, err := p.CallE("Get", context.Background(), "hello")
+	if err != nil || v[0].(string) != "hello" {
+		t.Fatal("expect hello but get ", v, err)
+	}
+	if o.calls != 3 || len(attempts) != 2 || !attempts[1].Retry {
+		t.Fatal("expect 3 calls and 2 attempts but get ", o.calls, attempts)
+	}
+
+	o.calls, o.failures = 0, 10
+	attempts = nil
+	_, err = p.CallE("Get", context.Background(), "hello")
+	if err != errFlaky || o.calls != 3 || attempts[2].Retry {
+		t.Fatal("expect errFlaky after 3 calls but get ", err, o.calls)
+	}
+}
+
+func TestRetryClassifierAndContext(t *testing.T) {
+	o := &flakyStruct{failures: 10}
+	p := aop.New(o)
+	p.AddAdvisor(aop.PointCutMethodName("Get"), retry.New(
+		retry.OptSetMaxAttempts(10),
+		retry.OptSetBackoff(retry.ExponentialBackoff(time.Millisecond, 10*time.Millisecond, 2)),
+		retry.OptSetClassifier(func(err error) bool {
+			return err != errFlaky
+		})))
+	_, err := p.CallE("Get", context.Background(), "hello")
+	if err != errFlaky || o.calls != 1 {
+		t.Fatal("expect no retry but get ", err, o.calls)
+	}
+
+	o.calls = 0
+	p = aop.New(o)
+	p.AddAdvisor(aop.PointCutMethodName("Get"), retry.New(
+		retry.OptSetMaxAttempts(10),
+		retry.OptSetBackoff(retry.ConstantBackoff(time.Hour))))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.CallE("Get", ctx, "hello")
+	if err != errFlaky || o.calls != 1 {
+		t.Fatal("expect stop retry after context done but get ", err, o.calls)
+	}
+
+	o.calls = 0
+	p = aop.New(o)
+	p.AddAdvisor(aop.PointCutMethodName("Get"), retry.New(
+		retry.OptSetMaxAttempts(10),
+		retry.OptSetMaxElapsed(5*time.Millisecond),
+		retry.OptSetBackoff(retry.ConstantBackoff(2*time.Millisecond))))
+	_, err = p.CallE("Get", context.Background(), "hello")
+	if err != errFlaky || o.calls >= 10 {
+		t.Fatal("expect stop retry after max elapsed but get ", err, o.calls)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/shadow"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type priceV1 struct{}
+
+func (s *priceV1) Price(ctx context.Context, sku string) (int, error) {
+	if sku == "" {
+		return 0, errors.New("empty sku")
+	}
+	return len(sku) * 10, nil
+}
+
+func (s *priceV1) Currency() string {
+	return "EUR"
+}
+
+type priceV2 struct{}
+
+func (s *priceV2) Price(ctx context.Context, sku string) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if sku == "" {
+		return 0, errors.New("empty sku")
+	}
+	if strings.HasPrefix(sku, "x") {
+		return 1, nil
+	}
+	return len(sku) * 10, nil
+}
+
+func TestShadow(t *testing.T) {
+	var lock sync.Mutex
+	var diffs []shadow.Diff
+	s := shadow.New(&priceV2{}, shadow.DiffReporterFunc(func(d shadow.Diff) {
+		lock.Lock()
+		diffs = append(diffs, d)
+		lock.Unlock()
+	}))
+	p := s.Proxy(&priceV1{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, sku := range []string{"abc", "", "xyz"} {
+		ret, err := p.CallE("Price", ctx, sku)
+		if sku == "" {
+			if err == nil {
+				t.Fatal("expect primary error")
+			}
+			continue
+		}
+		if err != nil || ret[0].(int) != 30 {
+			t.Fatal("expect primary result 30 but get ", ret, err)
+		}
+	}
+	// 主调用结束后取消context不影响影子调用
+	cancel()
+	if ret, _ := p.Call("Currency"); ret[0].(string) != "EUR" {
+		t.Fatal("expect EUR but get ", ret)
+	}
+	s.Wait()
+
+	if st := s.Stats(); st.Calls != 4 || st.Mismatches != 2 || st.Dropped != 0 {
+		t.Fatal("unexpected stats ", st)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	var priceDiff, missing *shadow.Diff
+	for i := range diffs {
+		switch diffs[i].Method {
+		case "Price":
+			priceDiff = &diffs[i]
+		case "Currency":
+			missing = &diffs[i]
+		}
+	}
+	if priceDiff == nil || priceDiff.Args[1] != "xyz" || priceDiff.Primary[0].(int) != 30 || priceDiff.Candidate[0].(int) != 1 {
+		t.Fatal("unexpected price diff ", priceDiff)
+	}
+	if missing == nil || !errors.Is(missing.CandidateErr, aop.ErrMethodNotFound) {
+		t.Fatal("expect method not found diff but get ", missing)
+	}
+}
+
+func TestShadowComparator(t *testing.T) {
+	reported := 0
+	s := shadow.New(&priceV2{}, shadow.DiffReporterFunc(func(d shadow.Diff) {
+		reported++
+	}), shadow.OptSetComparator(func(method string, primary, candidate []interface{}) bool {
+		return true
+	}), shadow.OptSetMaxConcurrent(1))
+	p := aop.New(&priceV1{})
+	p.AddAdvisor(aop.PointCutRegExp("", "Price", nil, nil), s.Advice())
+	p.CallE("Price", context.Background(), "xyz")
+	s.Wait()
+	if reported != 0 || s.Stats().Mismatches != 0 {
+		t.Fatal("expect custom comparator to accept results")
+	}
+}
+
+func TestShadowOuterAdviceMutatesResult(t *testing.T) {
+	s := shadow.New(&priceV1{}, shadow.DiffReporterFunc(func(d shadow.Diff) {
+		t.Error("unexpected diff ", d)
+	}))
+	p := aop.New(&priceV1{})
+	// 外层通知原地修改结果
+	p.AddAdvisor(aop.PointCutRegExp("", "Price", nil, nil), func(invocation aop.Invocation, params []interface{}) []interface{} {
+		v := invocation.Invoke(params)
+		v[0] = v[0].(int) + 1
+		return v
+	})
+	p.AddAdvisor(aop.PointCutRegExp("", "Price", nil, nil), s.Advice())
+	for i := 0; i < 20; i++ {
+		ret, err := p.CallE("Price", context.Background(), "abc")
+		if err != nil || ret[0].(int) != 31 {
+			t.Fatal("expect 31 but get ", ret, err)
+		}
+	}
+	s.Wait()
+	if st := s.Stats(); st.Calls != 20 || st.Mismatches != 0 {
+		t.Fatal("unexpected stats ", st)
+	}
+}
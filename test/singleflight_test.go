@@ -0,0 +1,152 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/singleflight"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errReport = errors.New("report failed")
+
+type reportService struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (s *reportService) Build(id int) (string, error) {
+	atomic.AddInt32(&s.calls, 1)
+	<-s.release
+	if id < 0 {
+		return "", errReport
+	}
+	return "report", nil
+}
+
+func (s *reportService) Crash(id int) string {
+	<-s.release
+	panic("crash")
+}
+
+func waitInFlight(t *testing.T, g *singleflight.Group, n, waiters int) {
+	deadline := time.Now().Add(time.Second)
+	for g.InFlight() != n || g.Waiters() != waiters {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect %d in flight with %d waiters, got %d with %d", n, waiters, g.InFlight(), g.Waiters())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSingleflight(t *testing.T) {
+	o := &reportService{release: make(chan struct{})}
+	p := aop.New(o)
+	g := singleflight.New()
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), g.Advice())
+
+	run := func(id int) ([]string, []error) {
+		var wg sync.WaitGroup
+		rets := make([]string, 5)
+		errs := make([]error, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ret, err := p.CallE("Build", id)
+				rets[i], errs[i] = ret[0].(string), err
+			}(i)
+		}
+		// 等待其他调用加入
+		waitInFlight(t, g, 1, 4)
+		o.release <- struct{}{}
+		wg.Wait()
+		return rets, errs
+	}
+
+	rets, errs := run(1)
+	for i := range rets {
+		if rets[i] != "report" || errs[i] != nil {
+			t.Fatal("unexpected result", rets[i], errs[i])
+		}
+	}
+	if n := atomic.LoadInt32(&o.calls); n != 1 {
+		t.Fatalf("expect 1 call, got %d", n)
+	}
+
+	_, errs = run(-1)
+	for _, err := range errs {
+		if !errors.Is(err, errReport) {
+			t.Fatal("expect shared error, got ", err)
+		}
+	}
+	if n := atomic.LoadInt32(&o.calls); n != 2 {
+		t.Fatalf("expect 2 calls, got %d", n)
+	}
+	if g.InFlight() != 0 || g.Waiters() != 0 {
+		t.Fatal("expect no call in flight")
+	}
+
+	// 不同参数不合并
+	go func() {
+		o.release <- struct{}{}
+		o.release <- struct{}{}
+	}()
+	var wg sync.WaitGroup
+	for _, id := range []int{2, 3} {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			p.CallE("Build", id)
+		}(id)
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&o.calls); n != 4 {
+		t.Fatalf("expect 4 calls, got %d", n)
+	}
+}
+
+func TestSingleflightPanic(t *testing.T) {
+	o := &reportService{release: make(chan struct{})}
+	p := aop.New(o, aop.OptSetRecoverPolicy(aop.RecoverError))
+	g := singleflight.New()
+	p.AddAdvisor(aop.PointCutRegExp("", "Crash", nil, nil), g.Advice())
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = p.CallE("Crash", 1)
+		}(i)
+	}
+	waitInFlight(t, g, 1, 2)
+	close(o.release)
+	wg.Wait()
+	for _, err := range errs {
+		var pe *aop.PanicError
+		if !errors.As(err, &pe) || pe.Value != "crash" {
+			t.Fatal("expect panic error, got ", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/timeout"
+	"testing"
+	"time"
+)
+
+type slowStruct struct{}
+
+func (t *slowStruct) WaitCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+func (t *slowStruct) Sleep(d time.Duration) (string, error) {
+	time.Sleep(d)
+	return "done", nil
+}
+
+func (t *slowStruct) SleepNoErr(d time.Duration) string {
+	time.Sleep(d)
+	return "done"
+}
+
+func TestTimeoutContext(t *testing.T) {
+	p := aop.New(&slowStruct{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), timeout.New(100*time.Millisecond))
+
+	_, err := p.CallE("WaitCtx", context.Background(), time.Millisecond)
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	_, err = p.CallE("WaitCtx", context.Background(), time.Second)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expect DeadlineExceeded but get ", err)
+	}
+}
+
+func TestTimeoutGoroutine(t *testing.T) {
+	late := make(chan timeout.Late, 1)
+	p := aop.New(&slowStruct{}, aop.OptSetRecoverPolicy(aop.RecoverError))
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), timeout.New(10*time.Millisecond,
+		timeout.OptSetOnLate(func(l timeout.Late) {
+			late <- l
+		})))
+
+	fast := aop.New(&slowStruct{})
+	fast.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), timeout.New(time.Second))
+	v, err := fast.CallE("Sleep", time.Millisecond)
+	if err != nil || v[0].(string) != "done" {
+		t.Fatal("expect done but get ", v, err)
+	}
+
+	start := time.Now()
+	v, err = p.CallE("Sleep", 200*time.Millisecond)
+	if !errors.Is(err, timeout.ErrTimeout) || v[0].(string) != "" {
+		t.Fatal("expect ErrTimeout but get ", v, err)
+	}
+	if time.Since(start) >= 200*time.Millisecond {
+		t.Fatal("expect return before target finished")
+	}
+
+	// 超时后目标方法继续执行直至返回，结果通过OptSetOnLate获取
+	select {
+	case l := <-late:
+		if l.Method != "Sleep" || l.Results[0].(string) != "done" || l.Elapsed < 200*time.Millisecond {
+			t.Fatal("expect late result of Sleep but get ", l)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect late result")
+	}
+
+	// 无error返回值时以TimeoutError panic
+	_, err = p.Call("SleepNoErr", 200*time.Millisecond)
+	if !errors.Is(err, timeout.ErrTimeout) {
+		t.Fatal("expect ErrTimeout but get ", err)
+	}
+	<-late
+}
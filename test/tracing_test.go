@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/tracing"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type traceStruct struct {
+	proxy aop.Proxy
+}
+
+func (t *traceStruct) Outer(ctx context.Context, s string) (string, error) {
+	ret, err := t.proxy.CallE("Inner", ctx, s)
+	if err != nil {
+		return "", err
+	}
+	return ret[0].(string), nil
+}
+
+func (t *traceStruct) Inner(ctx context.Context, s string) (string, error) {
+	if s == "" {
+		return "", errEmpty
+	}
+	return s, nil
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	tracer := tracing.NewTracer(exporter)
+	o := &traceStruct{}
+	p := aop.New(o)
+	o.proxy = p
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), tracer.Advice())
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, err := p.CallE("Outer", ctx, "hello")
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 3 {
+		t.Fatal("expect 3 spans but get ", len(spans))
+	}
+	inner, outer := spans[0], spans[1]
+	if inner.Name != "*test.traceStruct.Inner" || outer.Name != "*test.traceStruct.Outer" {
+		t.Fatal("expect Inner and Outer spans but get ", inner.Name, outer.Name)
+	}
+	if inner.ParentSpanID != outer.SpanID || outer.ParentSpanID != root.SpanID() {
+		t.Fatal("expect nested spans")
+	}
+	if inner.TraceID != root.TraceID() || outer.TraceID != root.TraceID() {
+		t.Fatal("expect same trace id")
+	}
+	if inner.Attributes["arg.1"] != "hello" || inner.Status != tracing.StatusOK {
+		t.Fatal("expect arg.1 hello and status ok but get ", inner.Attributes, inner.Status)
+	}
+
+	exporter.Reset()
+	p.CallE("Inner", context.Background(), "")
+	spans = exporter.Spans()
+	if len(spans) != 1 || spans[0].Status != tracing.StatusError || spans[0].StatusMsg != errEmpty.Error() {
+		t.Fatal("expect error span but get ", spans)
+	}
+}
+
+type requestCtx struct {
+	context.Context
+	Path string
+}
+
+type traceHandler struct{}
+
+func (h *traceHandler) Handle(c *requestCtx) string {
+	return c.Path
+}
+
+func TestTracingConcreteContext(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	tracer := tracing.NewTracer(exporter)
+	p := aop.New(&traceHandler{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), tracer.Advice())
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	ret, err := p.Call("Handle", &requestCtx{Context: ctx, Path: "/users"})
+	if err != nil || ret[0].(string) != "/users" {
+		t.Fatal("expect /users but get ", ret, err)
+	}
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].ParentSpanID != root.SpanID() {
+		t.Fatal("expect span with parent from concrete context but get ", spans)
+	}
+}
+
+func TestTracingJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := tracing.NewJSONFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := aop.New(&testStruct{})
+	p.AddAdvisor(aop.PointCutMethodName("Concat"), tracing.NewTracer(exporter).Advice())
+	p.Call("Concat", "hello", "world")
+	p.Call("Concat", "hello", "world")
+	exporter.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span tracing.SpanData
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatal(err)
+		}
+		t.Log(scanner.Text())
+		count++
+	}
+	if count != 2 {
+		t.Fatal("expect 2 spans but get ", count)
+	}
+}
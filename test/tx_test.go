@@ -0,0 +1,153 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/tx"
+	"github.com/xfali/aop/aspects/tx/txtest"
+	"reflect"
+	"testing"
+)
+
+type accountService struct {
+	proxy aop.Proxy
+	m     *tx.Manager
+}
+
+func (s *accountService) exec(ctx context.Context, query string) {
+	s.m.Executor(ctx).ExecContext(ctx, query)
+}
+
+func (s *accountService) Save(ctx context.Context, fail bool) error {
+	s.exec(ctx, "INSERT save")
+	if fail {
+		return errEmpty
+	}
+	return nil
+}
+
+func (s *accountService) Crash(ctx context.Context) error {
+	s.exec(ctx, "INSERT crash")
+	panic("crash")
+}
+
+func (s *accountService) Audit(ctx context.Context) error {
+	s.exec(ctx, "INSERT audit")
+	return nil
+}
+
+func (s *accountService) Step(ctx context.Context, fail bool) error {
+	s.exec(ctx, "INSERT step")
+	if fail {
+		return errEmpty
+	}
+	return nil
+}
+
+func (s *accountService) Find(ctx context.Context) error {
+	s.exec(ctx, "SELECT find")
+	return nil
+}
+
+// Transfer 调用其他事务方法，method为被调用的方法，忽略其错误
+func (s *accountService) Transfer(ctx context.Context, method string, fail bool) error {
+	s.exec(ctx, "UPDATE transfer")
+	switch method {
+	case "Save", "Step":
+		s.proxy.CallE(method, ctx, fail)
+	case "":
+	default:
+		s.proxy.CallE(method, ctx)
+	}
+	return nil
+}
+
+func newAccountService() (aop.Proxy, *txtest.Recorder) {
+	db, r := txtest.Open()
+	m := tx.New(db)
+	o := &accountService{m: m}
+	p := aop.New(o, aop.OptSetRecoverPolicy(aop.RecoverError))
+	o.proxy = p
+	p.AddAdvisor(aop.PointCutRegExp("", "^(Save|Crash|Transfer)$", nil, nil), m.Advice(tx.Definition{Propagation: tx.Required}))
+	p.AddAdvisor(aop.PointCutMethodName("Audit"), m.Advice(tx.Definition{Propagation: tx.RequiresNew}))
+	p.AddAdvisor(aop.PointCutMethodName("Step"), m.Advice(tx.Definition{Propagation: tx.Nested}))
+	p.AddAdvisor(aop.PointCutMethodName("Find"), m.Advice(tx.Definition{Propagation: tx.Supports}))
+	return p, r
+}
+
+func expectEvents(t *testing.T, r *txtest.Recorder, expects ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(r.Events(), expects) {
+		t.Fatal("expect ", expects, " but get ", r.Events())
+	}
+	r.Reset()
+}
+
+func TestTxRequired(t *testing.T) {
+	p, r := newAccountService()
+	ctx := context.Background()
+
+	if _, err := p.CallE("Save", ctx, false); err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	expectEvents(t, r, "1 BEGIN", "1 INSERT save", "1 COMMIT")
+
+	if _, err := p.CallE("Save", ctx, true); err != errEmpty {
+		t.Fatal("expect errEmpty but get ", err)
+	}
+	expectEvents(t, r, "1 BEGIN", "1 INSERT save", "1 ROLLBACK")
+
+	if _, err := p.CallE("Crash", ctx); !errors.Is(err, aop.ErrTargetPanic) {
+		t.Fatal("expect panic error but get ", err)
+	}
+	expectEvents(t, r, "1 BEGIN", "1 INSERT crash", "1 ROLLBACK")
+
+	// 加入的事务失败，外层事务回滚
+	if _, err := p.CallE("Transfer", ctx, "Save", true); err != tx.ErrRollbackOnly {
+		t.Fatal("expect ErrRollbackOnly but get ", err)
+	}
+	expectEvents(t, r, "1 BEGIN", "1 UPDATE transfer", "1 INSERT save", "1 ROLLBACK")
+}
+
+func TestTxPropagation(t *testing.T) {
+	p, r := newAccountService()
+	ctx := context.Background()
+
+	p.CallE("Transfer", ctx, "Audit", false)
+	expectEvents(t, r, "1 BEGIN", "1 UPDATE transfer", "2 BEGIN", "2 INSERT audit", "2 COMMIT", "1 COMMIT")
+
+	p.CallE("Transfer", ctx, "Step", true)
+	expectEvents(t, r, "1 BEGIN", "1 UPDATE transfer", "1 SAVEPOINT sp_1", "1 INSERT step",
+		"1 ROLLBACK TO SAVEPOINT sp_1", "1 COMMIT")
+
+	p.CallE("Transfer", ctx, "Step", false)
+	expectEvents(t, r, "1 BEGIN", "1 UPDATE transfer", "1 SAVEPOINT sp_1", "1 INSERT step",
+		"1 RELEASE SAVEPOINT sp_1", "1 COMMIT")
+
+	p.CallE("Transfer", ctx, "Find", false)
+	expectEvents(t, r, "1 BEGIN", "1 UPDATE transfer", "1 SELECT find", "1 COMMIT")
+
+	p.CallE("Find", ctx)
+	expectEvents(t, r, "1 SELECT find")
+
+	p.CallE("Step", ctx, false)
+	expectEvents(t, r, "1 BEGIN", "1 INSERT step", "1 COMMIT")
+}
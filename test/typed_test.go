@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/xfali/aop"
+	"testing"
+)
+
+type greetReq struct {
+	Name string
+}
+
+type greetResp struct {
+	Message string
+}
+
+type greetStruct struct{}
+
+func (t *greetStruct) Greet(ctx context.Context, req *greetReq) (*greetResp, error) {
+	if req.Name == "" {
+		return nil, errEmpty
+	}
+	return &greetResp{Message: "hello " + req.Name}, nil
+}
+
+func (t *greetStruct) GreetNoCtx(req *greetReq) (*greetResp, error) {
+	return t.Greet(context.Background(), req)
+}
+
+func TestTypedAround(t *testing.T) {
+	p := aop.New(&greetStruct{})
+	err := aop.AddTypedAdvisor(p, aop.PointCutRegExp("", "^Greet", nil, nil), aop.TypedAround(
+		func(ctx context.Context, req *greetReq, next func(*greetReq) (*greetResp, error)) (*greetResp, error) {
+			resp, err := next(&greetReq{Name: req.Name + "!"})
+			if err != nil {
+				return nil, err
+			}
+			resp.Message += "?"
+			return resp, nil
+		}))
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+
+	resp, err := aop.Call1[*greetResp](p, "Greet", context.Background(), &greetReq{Name: "world"})
+	if err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+	if resp.Message != "hello world!?" {
+		t.Fatal("expect hello world!? but get ", resp.Message)
+	}
+
+	resp, err = aop.Call1[*greetResp](p, "GreetNoCtx", &greetReq{Name: "world"})
+	if err != nil || resp.Message != "hello world!?" {
+		t.Fatal("expect hello world!? but get ", resp, err)
+	}
+
+	_, err = aop.Call1[*greetResp](p, "Greet", context.Background(), &greetReq{Name: ""})
+	if err != nil {
+		t.Fatal("expect nil because advice append ! but get ", err)
+	}
+}
+
+func TestTypedAroundMismatch(t *testing.T) {
+	p := aop.New(&testStruct{})
+	err := aop.AddTypedAdvisor(p, aop.PointCutMethodName("Concat"), aop.TypedAround(
+		func(ctx context.Context, req string, next func(string) (string, error)) (string, error) {
+			return next(req)
+		}))
+	if !errors.Is(err, aop.ErrSignatureMismatch) {
+		t.Fatal("expect ErrSignatureMismatch but get ", err)
+	}
+	t.Log(err)
+
+	s, n, err := aop.Call2[string, int](p, "Concat", "hello", "world")
+	if err != nil || s != "helloworld" || n != 10 {
+		t.Fatal("expect helloworld 10 but get ", s, n, err)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"github.com/xfali/aop"
+	"github.com/xfali/aop/aspects/validation"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `validate:"required"`
+}
+
+type createUserReq struct {
+	Name    string     `validate:"required,min=2,max=8"`
+	Email   string     `validate:"required,email"`
+	Age     int        `validate:"min=0,max=150"`
+	Role    string     `validate:"oneof=admin user"`
+	Tags    []string   `validate:"max=2"`
+	Address *address   `validate:"required"`
+	Others  []*address `validate:"len=1"`
+}
+
+type userService struct {
+	created int
+}
+
+func (s *userService) Create(req *createUserReq) (int, error) {
+	s.created++
+	return s.created, nil
+}
+
+func TestValidation(t *testing.T) {
+	o := &userService{}
+	p := aop.New(o)
+	p.AddAdvisor(aop.PointCutMethodName("Create"), validation.New())
+
+	v, err := p.CallE("Create", &createUserReq{
+		Name:    "tom",
+		Email:   "tom@example.com",
+		Age:     20,
+		Role:    "admin",
+		Address: &address{City: "x"},
+		Others:  []*address{{City: "y"}},
+	})
+	if err != nil || v[0].(int) != 1 {
+		t.Fatal("expect created but get ", v, err)
+	}
+
+	_, err = p.CallE("Create", &createUserReq{
+		Name:    "t",
+		Email:   "tom",
+		Age:     200,
+		Role:    "guest",
+		Tags:    []string{"a", "b", "c"},
+		Address: &address{},
+		Others:  []*address{{}},
+	})
+	if !errors.Is(err, validation.ErrValidation) {
+		t.Fatal("expect ErrValidation but get ", err)
+	}
+	t.Log(err)
+	var ve *validation.ValidationError
+	errors.As(err, &ve)
+	fields := map[string]string{}
+	for _, fe := range ve.Errors {
+		fields[fe.Field] = fe.Rule
+	}
+	expects := map[string]string{
+		"createUserReq.Name":           "min",
+		"createUserReq.Email":          "email",
+		"createUserReq.Age":            "max",
+		"createUserReq.Role":           "oneof",
+		"createUserReq.Tags":           "max",
+		"createUserReq.Address.City":   "required",
+		"createUserReq.Others[0].City": "required",
+	}
+	for k, r := range expects {
+		if fields[k] != r {
+			t.Fatal("expect ", k, " failed on ", r, " but get ", fields)
+		}
+	}
+	if len(ve.Errors) != len(expects) || o.created != 1 {
+		t.Fatal("expect target not invoked and all errors aggregated")
+	}
+}
+
+func TestValidationOptionalOneOf(t *testing.T) {
+	type req struct {
+		Role *string `validate:"oneof=admin user"`
+	}
+	if err := validation.Validate(&req{}); err != nil {
+		t.Fatal("expect nil pointer treated as not set but get ", err)
+	}
+	role := "guest"
+	if err := validation.Validate(&req{Role: &role}); !errors.Is(err, validation.ErrValidation) {
+		t.Fatal("expect validation error but get ", err)
+	}
+	role = "admin"
+	if err := validation.Validate(&req{Role: &role}); err != nil {
+		t.Fatal("expect nil but get ", err)
+	}
+}
+
+type badRuleReq struct {
+	Name string `validate:"required,mni=1"`
+}
+
+type badParamReq struct {
+	Items []badParamItem
+}
+
+type badParamItem struct {
+	Count int `validate:"max=ten"`
+}
+
+type badRuleService struct{}
+
+func (s *badRuleService) Create(req badRuleReq) error {
+	return nil
+}
+
+func TestValidationRuleError(t *testing.T) {
+	var rerr *validation.RuleError
+	if err := validation.CheckRules(badRuleReq{}); !errors.As(err, &rerr) || rerr.Field != "Name" || rerr.Rule != "mni=1" {
+		t.Fatal("expect rule error but get ", err)
+	}
+	if err := validation.CheckRules(reflect.TypeOf(&badParamReq{})); !errors.As(err, &rerr) || rerr.Field != "Count" || !errors.Is(err, validation.ErrInvalidRule) {
+		t.Fatal("expect nested param error but get ", err)
+	}
+	if err := validation.CheckRules(&createUserReq{}); err != nil {
+		t.Fatal("expect valid rules but get ", err)
+	}
+
+	p := aop.New(&badRuleService{})
+	p.AddAdvisor(aop.PointCutRegExp("", ".*", nil, nil), validation.New())
+	if _, err := p.CallE("Create", badRuleReq{Name: "tom"}); !errors.Is(err, validation.ErrInvalidRule) {
+		t.Fatal("expect rule error instead of panic but get ", err)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (C) 2022, Xiongfa Li.
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+type targeter interface {
+	target() reflect.Value
+}
+
+// TypedAdvice 类型化通知，通过AddTypedAdvisor注册时校验方法签名
+type TypedAdvice struct {
+	advice Advice
+	expect string
+	check  func(funcType reflect.Type) bool
+}
+
+// Advice 返回转换后的通知
+func (a *TypedAdvice) Advice() Advice {
+	return a.advice
+}
+
+// Check 校验方法签名是否匹配
+// method： 方法名
+// funcType： 方法类型（不包含接收者）
+func (a *TypedAdvice) Check(method string, funcType reflect.Type) error {
+	if !a.check(funcType) {
+		return &SignatureError{Method: method, Type: funcType, Expect: a.expect}
+	}
+	return nil
+}
+
+// TypedAround 将类型化的环绕通知转换为通知，匹配的方法签名需为
+// func(context.Context, Req) (Resp, error) 或 func(Req) (Resp, error)，
+// 后者调用advice时ctx为context.Background()
+// advice： 类型化通知，调用next执行后续的通知链及目标方法
+func TypedAround[Req, Resp any](advice func(ctx context.Context, req Req, next func(Req) (Resp, error)) (Resp, error)) *TypedAdvice {
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	respType := reflect.TypeOf((*Resp)(nil)).Elem()
+	return &TypedAdvice{
+		expect: fmt.Sprintf("func([context.Context, ]%s) (%s, error)", reqType.String(), respType.String()),
+		check: func(ft reflect.Type) bool {
+			if ft.IsVariadic() || ft.NumOut() != 2 || !sameType(ft.Out(0), respType) || ft.Out(1) != errorType {
+				return false
+			}
+			switch ft.NumIn() {
+			case 1:
+				return sameType(ft.In(0), reqType)
+			case 2:
+				return ft.In(0) == contextType && sameType(ft.In(1), reqType)
+			}
+			return false
+		},
+		advice: func(invocation Invocation, params []interface{}) []interface{} {
+			ctx := context.Background()
+			reqIndex := len(params) - 1
+			if reqIndex > 0 {
+				if c, ok := params[0].(context.Context); ok {
+					ctx = c
+				}
+			}
+			req, _ := params[reqIndex].(Req)
+			next := func(r Req) (Resp, error) {
+				ps := make([]interface{}, len(params))
+				copy(ps, params)
+				ps[reqIndex] = r
+				ret := invocation.Invoke(ps)
+				resp, _ := ret[0].(Resp)
+				err, _ := ret[1].(error)
+				return resp, err
+			}
+			resp, err := advice(ctx, req, next)
+			return []interface{}{resp, err}
+		},
+	}
+}
+
+func sameType(a, b reflect.Type) bool {
+	return a.AssignableTo(b) && b.AssignableTo(a)
+}
+
+// AddTypedAdvisor 注册类型化通知，切点匹配的方法签名不符合时返回错误且不注册
+func AddTypedAdvisor(p Proxy, pointCut PointCut, advice *TypedAdvice) error {
+	t, ok := p.(targeter)
+	if !ok {
+		return errors.New("aop: proxy does not support typed advice")
+	}
+	v := t.target()
+	vt := v.Type()
+	for i := 0; i < vt.NumMethod(); i++ {
+		m := vt.Method(i)
+		if !pointCut.Matches(m, vt) {
+			continue
+		}
+		if err := advice.Check(m.Name, v.Method(i).Type()); err != nil {
+			return err
+		}
+	}
+	p.AddAdvisor(pointCut, advice.advice)
+	return nil
+}
+
+// Call1 调用方法并返回类型化结果，方法最后一个error返回值处理同Proxy.CallInto
+func Call1[T any](p Proxy, method string, params ...interface{}) (T, error) {
+	var r T
+	err := p.CallInto(method, []interface{}{&r}, params...)
+	return r, err
+}
+
+// Call2 调用方法并返回两个类型化结果，方法最后一个error返回值处理同Proxy.CallInto
+func Call2[T1, T2 any](p Proxy, method string, params ...interface{}) (T1, T2, error) {
+	var r1 T1
+	var r2 T2
+	err := p.CallInto(method, []interface{}{&r1, &r2}, params...)
+	return r1, r2, err
+}